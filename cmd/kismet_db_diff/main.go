@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <before.kismet> <after.kismet>\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func openAll(ctx context.Context, paths ...string) ([]*data.KismetDatabase, error) {
+	dbs := make([]*data.KismetDatabase, 0, len(paths))
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return dbs, fmt.Errorf("kismet db access failure: %w", err)
+		}
+		db, err := data.OpenKismetDatabaseReadOnlyCtx(ctx, path)
+		if err != nil {
+			return dbs, err
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
+
+func main() {
+	var (
+		asJSON    bool
+		logFormat string
+		logLevel  slog.Level
+		timeout   time.Duration
+	)
+
+	flag.Usage = usage
+	flag.BoolVar(&asJSON, "json", false, "write the diff as JSON")
+	flag.DurationVar(&timeout, "timeout", 10*time.Minute, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	dbs, err := openAll(ctx, flag.Arg(0), flag.Arg(1))
+	defer func() {
+		for _, db := range dbs {
+			_ = db.Close()
+		}
+	}()
+	if err != nil {
+		logger.Error("failed to open databases", "err", err)
+		os.Exit(1)
+	}
+
+	diff, err := data.DiffKismetDatabasesCtx(ctx, dbs[0], dbs[1])
+	if diff == nil {
+		logger.Error("failed to diff", "err", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Warn("some devices could not be compared", "err", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(diff)
+	} else {
+		err = diff.WriteText(os.Stdout)
+	}
+
+	if err != nil {
+		logger.Error("failed to write diff", "err", err)
+		os.Exit(1)
+	}
+}
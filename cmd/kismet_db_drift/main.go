@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <kismet db> [kismet db...]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// drift scans the devices of the log at path, which is opened read-only, so a file that isn't
+// a Kismet log is reported rather than given the Kismet schema.
+func drift(ctx context.Context, path string) (*data.DriftReport, error) {
+	db, err := data.OpenKismetDatabaseReadOnlyCtx(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	return db.SchemaDrift(ctx)
+}
+
+func main() {
+	var (
+		asJSON     bool
+		minDevices int
+		logFormat  string
+		logLevel   slog.Level
+		timeout    time.Duration
+	)
+
+	flag.Usage = usage
+	flag.BoolVar(&asJSON, "json", false, "write the reports as JSON")
+	flag.IntVar(&minDevices, "min", 1, "leave out drifts seen in fewer devices than this")
+	flag.DurationVar(&timeout, "timeout", 0, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reports []*data.DriftReport
+	for _, path := range flag.Args() {
+		report, err := drift(ctx, path)
+		if err != nil {
+			logger.Error("failed to check schema drift", "path", path, "err", err)
+			os.Exit(1)
+		}
+		reports = append(reports, report)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(reports)
+	} else {
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err = report.WriteText(os.Stdout, minDevices); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		logger.Error("failed to write report", "err", err)
+		os.Exit(1)
+	}
+}
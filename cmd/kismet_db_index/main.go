@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <log dir> [mac]...\n\n", os.Args[0])
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "updates the mac index in <log dir>, then reports where each mac was seen\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		related   bool
+		noUpdate  bool
+		logFormat string
+		logLevel  slog.Level
+		timeout   time.Duration
+	)
+
+	flag.Usage = usage
+	flag.BoolVar(&related, "related", false, "also list the macs that exchanged packets with each mac")
+	flag.BoolVar(&noUpdate, "no-update", false, "query the index without picking up new logs first")
+	flag.DurationVar(&timeout, "timeout", 0, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	idx, err := data.OpenMACIndex(flag.Arg(0))
+	if err != nil {
+		logger.Error("failed to open mac index", "err", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if !noUpdate {
+		var update *data.MACIndexUpdate
+		if update, err = idx.Update(ctx); err != nil {
+			logger.Error("failed to update mac index", "err", err)
+			_ = idx.Close()
+			os.Exit(1)
+		}
+		logger.Info("updated mac index", "index", idx.String(), "update", update.String())
+	}
+
+	exit := 0
+
+	for _, mac := range flag.Args()[1:] {
+		found, lookupErr := idx.Lookup(ctx, mac)
+		if lookupErr != nil {
+			logger.Error("lookup failed", "mac", mac, "err", lookupErr)
+			exit = 1
+			continue
+		}
+		if len(found) == 0 {
+			fmt.Println(mac + ": never seen")
+			continue
+		}
+		for _, mp := range found {
+			fmt.Println(mp.String())
+		}
+		if !related {
+			continue
+		}
+		macs, relatedErr := idx.FindRelatedMacs(ctx, mac)
+		if relatedErr != nil {
+			logger.Error("failed to find related macs", "mac", mac, "err", relatedErr)
+			exit = 1
+		}
+		for _, r := range macs {
+			fmt.Println("  related: " + r)
+		}
+	}
+
+	if err = idx.Close(); err != nil {
+		logger.Error("failed to close mac index", "err", err)
+		exit = 1
+	}
+
+	os.Exit(exit)
+}
@@ -1,38 +1,51 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/dustin/go-humanize"
+
 	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
 )
 
-func optimize(targetDB *data.KismetDatabase) error {
+// setupTimeout bounds the quick steps around a merge: opening the target, setting and
+// restoring pragmas, and closing. The merge itself is only bounded by -timeout.
+const setupTimeout = 30 * time.Second
+
+func optimize(ctx context.Context, targetDB *data.KismetDatabase) error {
 	var err error
 
-	println("enabling WAL...")
-	if err = targetDB.EnableWAL(true); err != nil {
+	targetDB.Logger().Info("enabling WAL")
+	if err = targetDB.EnableWALCtx(ctx, true); err != nil {
 		return err
 	}
 
-	println("enabling async...")
-	if err = targetDB.EnableAsync(true); err != nil {
+	targetDB.Logger().Info("enabling async")
+	if err = targetDB.EnableAsyncCtx(ctx, true); err != nil {
 		return err
 	}
 
-	println("setting journal size limit...")
-	err = targetDB.JournalSizeLimit(6144000)
+	targetDB.Logger().Info("setting journal size limit")
+	err = targetDB.JournalSizeLimitCtx(ctx, 6144000)
 
 	return err
 }
 
-func restorePragma(targetDB *data.KismetDatabase) error {
+func restorePragma(ctx context.Context, targetDB *data.KismetDatabase) error {
 	var errs = make([]error, 0, 3)
 
 	for _, p := range []data.Pragma{data.PragmaJournalMode, data.PragmaSynchronous, data.PragmaJournalSizeLimit} {
-		if err := targetDB.RestorePragma(p); err != nil {
+		if err := targetDB.RestorePragmaCtx(ctx, p); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -40,11 +53,83 @@ func restorePragma(targetDB *data.KismetDatabase) error {
 	return errors.Join(errs...)
 }
 
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <target.kismet> <source.kismet>...\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
 func main() {
+	var (
+		opts       = &data.MergeOptions{}
+		tidyName   string
+		tmpDir     string
+		groupBytes string
+		tables     string
+		exclude    string
+		retry      = data.DefaultRetryPolicy
+		logFormat  string
+		logLevel   slog.Level
+		timeout    time.Duration
+	)
+
+	flag.Usage = usage
+	flag.StringVar(&tidyName, "tidy", data.TidyAtEnd.String(), "when to compact the target: end, never, group, incremental or into")
+	flag.StringVar(&opts.VacuumInto, "vacuum-into", "", "destination of the compacted copy when using -tidy into")
+	flag.StringVar(&tmpDir, "tmpdir", "", "directory for sqlite temp files (default ./.sqlite_tmp)")
+	flag.StringVar(&tables, "tables", "all", "tables to merge: all, metadata (everything but packets), or a comma separated list")
+	flag.StringVar(&exclude, "exclude", "", "comma separated list of tables to skip")
+	flag.IntVar(&opts.GroupSize, "group", 0, "sources attached at once to the target, then copied one after the other (default: 10, or sqlite's attach limit if lower)")
+	flag.StringVar(&groupBytes, "group-bytes", "", "cap on the combined size of sources attached at once, e.g. 8GiB")
+	flag.Int64Var(&opts.ChunkRows, "chunk", data.DefaultChunkRows, "rows copied per transaction before committing and checkpointing")
+	flag.IntVar(&retry.MaxAttempts, "retries", retry.MaxAttempts, "attempts at a write that finds the target busy, 0 for no limit")
+	flag.DurationVar(&retry.MaxDelay, "retry-max-delay", retry.MaxDelay, "longest wait between attempts at a busy write")
+	flag.DurationVar(&retry.Deadline, "retry-deadline", retry.Deadline, "time limit on a single busy write, retries included, 0 for no limit")
+	flag.DurationVar(&retry.BusyTimeout, "busy-timeout", retry.BusyTimeout, "how long sqlite waits on a lock before reporting the target busy")
+	flag.BoolVar(&opts.SkipSpaceCheck, "skip-space-check", false, "merge even if the disk space preflight fails")
+	flag.DurationVar(&timeout, "timeout", 0, "give up on the merge after this long, 0 for no limit; an interrupted merge resumes on the next run")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug (includes every chunk), info, warn or error")
+	flag.Parse()
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	if opts.Tidy, err = data.ParseTidyStrategy(tidyName); err != nil {
+		logger.Error("bad -tidy value", "err", err)
+		os.Exit(2)
+	}
+
+	if opts.Tables, err = data.ParseTableSelection(tables); err != nil {
+		logger.Error("bad -tables value", "err", err)
+		os.Exit(2)
+	}
+	for _, t := range strings.Split(exclude, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			opts.Tables.Exclude = append(opts.Tables.Exclude, t)
+		}
+	}
+
+	if groupBytes != "" {
+		var gb uint64
+		if gb, err = humanize.ParseBytes(groupBytes); err != nil {
+			logger.Error("bad -group-bytes value", "err", err)
+			os.Exit(2)
+		}
+		opts.GroupBytes = int64(gb)
+	}
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	var target string
-	var sources = make([]string, 0, len(os.Args[1:])-1)
-	for i, arg := range os.Args[1:] {
-		_, err := os.Stat(arg)
+	var sources = make([]string, 0, flag.NArg()-1)
+	for i, arg := range flag.Args() {
+		_, err = os.Stat(arg)
 		if errors.Is(err, os.ErrNotExist) && i == 0 {
 			var f *os.File
 			f, err = os.Create(arg)
@@ -53,7 +138,7 @@ func main() {
 			}
 		}
 		if err != nil {
-			println("kismet db access failure: ", err.Error())
+			logger.Error("kismet db access failure", "err", err)
 			os.Exit(1)
 		}
 		if i == 0 {
@@ -63,43 +148,71 @@ func main() {
 		sources = append(sources, arg)
 	}
 
-	targetDB, err := data.OpenKismetDatabase(target)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	setupCtx, cancelSetup := context.WithTimeout(ctx, setupTimeout)
+	defer cancelSetup()
+
+	targetDB, err := data.OpenKismetDatabaseCtx(setupCtx, target)
 	if err != nil {
-		print(err.Error())
+		logger.Error("failed to open target", "err", err)
 		os.Exit(1)
 	}
 
-	if err = optimize(targetDB); err != nil {
-		print(err.Error())
-		os.Exit(1)
+	if err = targetDB.SetRetryPolicy(retry); err != nil {
+		logger.Error("bad retry policy", "err", err)
+		os.Exit(2)
 	}
 
-	if cwd, _ := os.Getwd(); cwd != "" {
-		targetDB.SetTmpDir(filepath.Join(cwd, ".sqlite_tmp"))
+	if err = optimize(setupCtx, targetDB); err != nil {
+		logger.Error("failed to prepare target", "err", err)
+		os.Exit(1)
 	}
 
-	defer func() {
-		if err = restorePragma(targetDB); err != nil {
-			println(err.Error())
-		} else {
-			println("pragma restored")
+	if tmpDir == "" {
+		if cwd, _ := os.Getwd(); cwd != "" {
+			tmpDir = filepath.Join(cwd, ".sqlite_tmp")
+		}
+	}
+	if tmpDir != "" {
+		if err = targetDB.SetTmpDirCtx(setupCtx, tmpDir); err != nil {
+			logger.Warn("unable to set tmp dir", "dir", tmpDir, "err", err)
 		}
+	}
 
-		println("closing " + targetDB.String())
+	mergeCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		mergeCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-		for err = targetDB.Close(); err != nil; err = targetDB.Close() {
-			println(targetDB.String() + ": " + err.Error())
-			time.Sleep(1 * time.Second)
-		}
-		println("db closed")
+	exit := 0
+	if err = data.MergeKismetDatabasesCtx(mergeCtx, targetDB, opts, sources...); err != nil {
+		logger.Error("merge failed", "err", err)
+		exit = 1
+	}
 
-		println("fin.")
+	// clean up even after an interrupt, the merge itself is what gets cancelled
+	cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancelCleanup()
 
-		os.Exit(0)
-	}()
+	if err = restorePragma(cleanupCtx, targetDB); err != nil {
+		logger.Warn("failed to restore pragma", "err", err)
+	} else {
+		logger.Info("pragma restored")
+	}
 
-	if err = data.MergeKismetDatabases(targetDB, sources...); err != nil {
-		print(err.Error())
-		os.Exit(1)
+	logger.Info("closing", "db", targetDB.String())
+	if err = targetDB.CloseCtx(cleanupCtx); err != nil {
+		logger.Error("failed to close", "db", targetDB.String(), "err", err)
+		exit = 1
+	} else {
+		logger.Info("db closed")
 	}
+
+	logger.Info("fin.")
+
+	os.Exit(exit)
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [mac...]\n\n", os.Args[0])
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "looks up the vendors of MACs, or with -fetch updates a registry from IEEE, e.g.\n")
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "  %s -fetch oui.csv\n  %s -registry oui.csv 00:03:93:12:34:56\n\n", os.Args[0], os.Args[0])
+	flag.PrintDefaults()
+}
+
+// fetch downloads IEEE's registries and writes them to path.
+func fetch(ctx context.Context, path string) (int, error) {
+	reg, err := data.FetchOUIRegistry(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	if err = reg.WriteCSV(f); err != nil {
+		_ = f.Close()
+		return 0, err
+	}
+	return reg.Len(), f.Close()
+}
+
+func main() {
+	var (
+		fetchPath string
+		registry  string
+		logFormat string
+		logLevel  slog.Level
+		timeout   time.Duration
+	)
+
+	flag.Usage = usage
+	flag.StringVar(&fetchPath, "fetch", "", "download IEEE's MA-L, MA-M and MA-S registries to this file")
+	flag.StringVar(&registry, "registry", "", "look up vendors in this registry instead of the embedded one")
+	flag.DurationVar(&timeout, "timeout", 5*time.Minute, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if fetchPath == "" && flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if fetchPath != "" {
+		n, err := fetch(ctx, fetchPath)
+		if err != nil {
+			logger.Error("failed to fetch oui registry", "err", err)
+			os.Exit(1)
+		}
+		logger.Info("fetched oui registry", "path", fetchPath, "assignments", n)
+	}
+
+	if registry != "" {
+		reg, err := data.LoadOUIRegistry(registry)
+		if err != nil {
+			logger.Error("failed to load registry", "err", err)
+			os.Exit(1)
+		}
+		data.SetOUIRegistry(reg)
+	}
+
+	for _, mac := range flag.Args() {
+		vendor, ok := data.OUIRegistryInUse().Lookup(mac)
+		if !ok {
+			vendor = "-"
+		}
+		if data.IsRandomizedMAC(mac) {
+			vendor += " (randomized)"
+		}
+		fmt.Printf("%s\t%s\n", mac, vendor)
+	}
+}
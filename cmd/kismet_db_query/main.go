@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <query> <source.kismet>...\n\n", os.Args[0])
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "tables in the query must be qualified with %s, e.g.\n", data.FederatedSourcePlaceholder)
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "  %s -order 'last_time DESC' -limit 10 'SELECT devmac, last_time FROM {src}.devices' *.kismet\n\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func format(v any) string {
+	switch vv := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(vv)
+	default:
+		return fmt.Sprint(vv)
+	}
+}
+
+func main() {
+	var (
+		fq        = &data.FederatedQuery{}
+		group     int
+		logFormat string
+		logLevel  slog.Level
+		timeout   time.Duration
+	)
+
+	flag.Usage = usage
+	flag.StringVar(&fq.OrderBy, "order", "", "ORDER BY clause applied across all sources")
+	flag.IntVar(&fq.Limit, "limit", 0, "maximum number of rows returned across all sources")
+	flag.IntVar(&group, "group", 0, "sources attached at once (default: 10, or sqlite's attach limit if lower)")
+	flag.DurationVar(&timeout, "timeout", 5*time.Minute, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	fq.Query = flag.Arg(0)
+
+	for _, source := range flag.Args()[1:] {
+		if _, err := os.Stat(source); err != nil {
+			logger.Error("kismet db access failure", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	fed, err := data.NewFederation(group, flag.Args()[1:]...)
+	if err != nil {
+		logger.Error("failed to set up federation", "err", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	rows, err := fed.Query(ctx, fq)
+	if err != nil {
+		logger.Error("query failed", "err", err)
+		_ = fed.Close()
+		os.Exit(1)
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		logger.Error("failed to read columns", "err", err)
+		os.Exit(1)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	_, _ = out.WriteString(strings.Join(cols, "\t") + "\n")
+
+	var (
+		values = make([]any, len(cols))
+		ptrs   = make([]any, len(cols))
+		fields = make([]string, len(cols))
+	)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(ptrs...); err != nil {
+			break
+		}
+		for i, v := range values {
+			fields[i] = format(v)
+		}
+		_, _ = out.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+
+	if err == nil {
+		err = rows.Err()
+	}
+
+	_ = out.Flush()
+	_ = rows.Close()
+	_ = fed.Close()
+
+	if err != nil {
+		logger.Error("failed to read results", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <source.kismet> [mac...]\n\nwithout MACs, every device matching -phy, -vendor, -randomized and -crypt is exported\n\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// deviceSeries decodes the RRDs of every device matching dq with one of macs, or of every
+// device matching dq without macs.
+func deviceSeries(ctx context.Context, db *data.KismetDatabase, dq data.DeviceQuery, macs []string) ([]*data.DeviceSeries, error) {
+	if len(macs) == 0 {
+		iter, err := db.Devices(ctx, &dq)
+		if err != nil {
+			return nil, err
+		}
+		devices, err := iter.All()
+		if err != nil {
+			return nil, err
+		}
+		all := make([]*data.DeviceSeries, 0, len(devices))
+		for _, d := range devices {
+			all = append(all, d.Series())
+		}
+		return all, nil
+	}
+
+	var all []*data.DeviceSeries
+	for _, mac := range macs {
+		dq.MAC = mac
+		iter, err := db.Devices(ctx, &dq)
+		if err != nil {
+			return nil, err
+		}
+		devices, err := iter.All()
+		if err != nil {
+			return nil, err
+		}
+		if len(devices) == 0 {
+			return nil, fmt.Errorf("%s: %w", mac, data.ErrDeviceNotFound)
+		}
+		for _, d := range devices {
+			all = append(all, d.Series())
+		}
+	}
+	return all, nil
+}
+
+func main() {
+	var (
+		format    string
+		dq        data.DeviceQuery
+		logFormat string
+		logLevel  slog.Level
+		timeout   time.Duration
+	)
+
+	flag.Usage = usage
+	flag.StringVar(&format, "format", "csv", "output: csv or json")
+	flag.StringVar(&dq.PhyName, "phy", "", "only export devices of this phy, such as IEEE802.11")
+	flag.StringVar(&dq.Vendor, "vendor", "", "only export devices whose MAC is registered to a vendor whose name contains this")
+	flag.Func("randomized", "only export devices whose MAC is, true, or isn't, false, randomized", func(s string) error {
+		v, err := strconv.ParseBool(s)
+		dq.Randomized = &v
+		return err
+	})
+	flag.Func("crypt", "only export 802.11 devices advertising a network of this encryption: open, wep, wpa, wpa2, wpa3, sae, owe, psk, enterprise or wps", func(s string) (err error) {
+		dq.Dot11Crypt, err = data.ParseDot11Encryption(s)
+		return err
+	})
+	flag.DurationVar(&timeout, "timeout", 0, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if flag.NArg() < 1 || (format != "csv" && format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	path := flag.Arg(0)
+	if _, err := os.Stat(path); err != nil {
+		logger.Error("kismet db access failure", "err", err)
+		os.Exit(1)
+	}
+	db, err := data.OpenKismetDatabaseReadOnlyCtx(ctx, path)
+	if err != nil {
+		logger.Error("failed to open database", "err", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	series, err := deviceSeries(ctx, db, dq, flag.Args()[1:])
+	if err != nil {
+		logger.Error("failed to read devices", "err", err)
+		os.Exit(1)
+	}
+
+	if format == "json" {
+		err = data.WriteSeriesJSON(os.Stdout, series...)
+	} else {
+		err = data.WriteSeriesCSV(os.Stdout, series...)
+	}
+	if err != nil {
+		logger.Error("failed to write series", "err", err)
+		os.Exit(1)
+	}
+}
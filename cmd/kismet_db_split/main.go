@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.tcp.direct/kayos/kismet2mdk/pkg/data"
+)
+
+func usage() {
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <source.kismet>\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		opts      = &data.SplitOptions{}
+		by        string
+		outDir    string
+		logFormat string
+		logLevel  slog.Level
+		timeout   time.Duration
+	)
+
+	flag.Usage = usage
+	flag.StringVar(&by, "by", "day", "partition by: day, hour, datasource, server or phy")
+	flag.DurationVar(&opts.Window, "window", time.Hour, "width of each partition when splitting by hour, in whole hours")
+	flag.StringVar(&outDir, "out", ".", "directory to write the split databases to")
+	flag.StringVar(&opts.Prefix, "prefix", "", "output file name prefix (default: source file name)")
+	flag.DurationVar(&timeout, "timeout", 0, "give up after this long, 0 for no limit")
+	flag.StringVar(&logFormat, "log-format", "text", "log output: text or json")
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "minimum level logged: debug, info, warn or error")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := data.SetupCommandLogging(logFormat, logLevel)
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+
+	switch by {
+	case "day":
+		opts.Mode, opts.Window = data.SplitByTime, 24*time.Hour
+	case "hour":
+		opts.Mode = data.SplitByTime
+	default:
+		if opts.Mode, err = data.ParseSplitMode(by); err != nil {
+			logger.Error("bad -by value", "err", err)
+			os.Exit(2)
+		}
+	}
+
+	source := flag.Arg(0)
+	if _, err = os.Stat(source); err != nil {
+		logger.Error("kismet db access failure", "err", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	sourceDB, err := data.OpenKismetDatabaseReadOnlyCtx(ctx, source)
+	if err != nil {
+		logger.Error("failed to open source", "source", source, "err", err)
+		os.Exit(1)
+	}
+
+	shards, err := data.SplitKismetDatabaseCtx(ctx, sourceDB, outDir, opts)
+	for _, shard := range shards {
+		logger.Info("wrote shard", "shard", shard.Key, "path", shard.Path, "rows", shard.Rows)
+	}
+
+	if closeErr := sourceDB.Close(); closeErr != nil {
+		logger.Error("failed to close source", "err", closeErr)
+	}
+
+	if err != nil {
+		logger.Error("split failed", "err", err)
+		os.Exit(1)
+	}
+
+	logger.Info("fin.", "shards", len(shards))
+}
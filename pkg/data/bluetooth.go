@@ -0,0 +1,357 @@
+package data
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HexBytes is binary data that Kismet logs as a hex string.
+type HexBytes []byte
+
+func (hb HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(hb)), nil
+}
+
+// UnmarshalText decodes hex, ignoring the colons or spaces some Kismet releases separate bytes with.
+func (hb *HexBytes) UnmarshalText(b []byte) error {
+	s := strings.NewReplacer(":", "", " ", "", "-", "").Replace(string(b))
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*hb = decoded
+	return nil
+}
+
+// Bluetooth is the bluetooth.device record that Kismet logs for Bluetooth classic and BTLE
+// devices seen by an HCI datasource.
+type Bluetooth struct {
+	ServiceUUIDs      []string `json:"bluetooth.device.service_uuid_vec"`
+	SolicitationUUIDs []string `json:"bluetooth.device.solicitation_uuid_vec"`
+	// ServiceData is the data advertised for each service, keyed by service UUID.
+	ServiceData map[string]HexBytes `json:"bluetooth.device.service_data_bytes"`
+	// ManufacturerData is the manufacturer specific data advertised, keyed by company identifier.
+	ManufacturerData map[string]HexBytes `json:"bluetooth.device.manuf_data_bytes"`
+	// ScanData is the raw advertisement and scan response.
+	ScanData HexBytes `json:"bluetooth.device.scan_data_bytes"`
+	TxPower  int      `json:"bluetooth.device.txpower"`
+	PathLoss int      `json:"bluetooth.device.pathloss"`
+
+	// Unknown holds the keys of the bluetooth record that Bluetooth doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type bluetooth Bluetooth
+
+func (bt *Bluetooth) UnmarshalJSON(b []byte) (err error) {
+	bt.Unknown, bt.known, err = decodeLossless(b, (*bluetooth)(bt))
+	return err
+}
+
+func (bt Bluetooth) MarshalJSON() ([]byte, error) {
+	return encodeLossless(bluetooth(bt), bt.Unknown, bt.known)
+}
+
+// Advertisement returns what the device advertised, from its scan data and the fields
+// Kismet decoded from it.
+func (bt *Bluetooth) Advertisement() *Advertisement {
+	adv := parseAdvertisementLenient(bt.ScanData)
+	adv.addServices(bt.ServiceUUIDs, bt.ServiceData)
+	adv.addManufacturerData(bt.ManufacturerData)
+	if !adv.HasTxPower && bt.TxPower != 0 {
+		adv.TxPower, adv.HasTxPower = bt.TxPower, true
+	}
+	return adv
+}
+
+// BTLE is the btle.device record that Kismet logs for devices seen by a BTLE sniffer,
+// such as an nRF or Ubertooth.
+type BTLE struct {
+	// AdvertisedName is the local name the device advertised.
+	AdvertisedName string   `json:"btle.device.name"`
+	ServiceUUIDs   []string `json:"btle.device.service_uuid_vec"`
+	// ServiceData is the data advertised for each service, keyed by service UUID.
+	ServiceData map[string]HexBytes `json:"btle.device.service_data"`
+	// ManufacturerData is the manufacturer specific data advertised, keyed by company identifier.
+	ManufacturerData map[string]HexBytes `json:"btle.device.manuf_data"`
+	// AdvData is the raw advertisement.
+	AdvData HexBytes `json:"btle.device.adv_data"`
+	TxPower int      `json:"btle.device.txpower"`
+
+	// Unknown holds the keys of the btle record that BTLE doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type btle BTLE
+
+func (le *BTLE) UnmarshalJSON(b []byte) (err error) {
+	le.Unknown, le.known, err = decodeLossless(b, (*btle)(le))
+	return err
+}
+
+func (le BTLE) MarshalJSON() ([]byte, error) {
+	return encodeLossless(btle(le), le.Unknown, le.known)
+}
+
+// Advertisement returns what the device advertised, from its raw advertisement and the
+// fields Kismet decoded from it.
+func (le *BTLE) Advertisement() *Advertisement {
+	adv := parseAdvertisementLenient(le.AdvData)
+	if le.AdvertisedName != "" {
+		adv.Name = le.AdvertisedName
+	}
+	adv.addServices(le.ServiceUUIDs, le.ServiceData)
+	adv.addManufacturerData(le.ManufacturerData)
+	if !adv.HasTxPower && le.TxPower != 0 {
+		adv.TxPower, adv.HasTxPower = le.TxPower, true
+	}
+	return adv
+}
+
+// Advertisement is the content of a BLE advertisement and scan response.
+type Advertisement struct {
+	// Name is the shortened or complete local name.
+	Name string
+	// TxPower is the advertised transmit power in dBm, if HasTxPower.
+	TxPower    int
+	HasTxPower bool
+	// ServiceUUIDs are the advertised services, see [NormalizeUUID] for their form.
+	ServiceUUIDs []string
+	// ServiceData is keyed by service UUID.
+	ServiceData map[string][]byte
+	// ManufacturerData is keyed by company identifier.
+	ManufacturerData map[uint16][]byte
+}
+
+// AD types of the advertisement data structures that Advertisement decodes.
+const (
+	adIncompleteUUID16  = 0x02
+	adCompleteUUID16    = 0x03
+	adIncompleteUUID128 = 0x06
+	adCompleteUUID128   = 0x07
+	adShortName         = 0x08
+	adCompleteName      = 0x09
+	adTxPower           = 0x0a
+	adServiceData16     = 0x16
+	adManufacturerData  = 0xff
+)
+
+// ErrBadAdvertisement is returned for an advertisement whose structures overrun it.
+var ErrBadAdvertisement = errors.New("malformed advertisement")
+
+// ParseAdvertisement decodes the advertisement data structures of a BLE advertisement or
+// scan response, as in the Bluetooth Core Specification, Vol 3, Part C, 11.
+func ParseAdvertisement(b []byte) (*Advertisement, error) {
+	adv := &Advertisement{}
+	for len(b) > 0 {
+		n := int(b[0])
+		if n == 0 {
+			// the rest is padding
+			break
+		}
+		if n >= len(b) {
+			return adv, fmt.Errorf("%w: structure of %d bytes in %d", ErrBadAdvertisement, n, len(b)-1)
+		}
+		adType, data := b[1], b[2:n+1]
+		b = b[n+1:]
+
+		switch adType {
+		case adIncompleteUUID16, adCompleteUUID16:
+			for ; len(data) >= 2; data = data[2:] {
+				adv.addService(fmt.Sprintf("%04X", binary.LittleEndian.Uint16(data)), nil)
+			}
+		case adIncompleteUUID128, adCompleteUUID128:
+			for ; len(data) >= 16; data = data[16:] {
+				adv.addService(uuid128(data[:16]), nil)
+			}
+		case adShortName:
+			if adv.Name == "" {
+				adv.Name = string(data)
+			}
+		case adCompleteName:
+			adv.Name = string(data)
+		case adTxPower:
+			if len(data) == 1 {
+				adv.TxPower, adv.HasTxPower = int(int8(data[0])), true
+			}
+		case adServiceData16:
+			if len(data) >= 2 {
+				adv.addService(fmt.Sprintf("%04X", binary.LittleEndian.Uint16(data)), data[2:])
+			}
+		case adManufacturerData:
+			if len(data) >= 2 {
+				if adv.ManufacturerData == nil {
+					adv.ManufacturerData = make(map[uint16][]byte)
+				}
+				adv.ManufacturerData[binary.LittleEndian.Uint16(data)] = data[2:]
+			}
+		}
+	}
+	return adv, nil
+}
+
+// parseAdvertisementLenient is ParseAdvertisement keeping what precedes a malformed structure,
+// as Kismet logs scan data truncated at times.
+func parseAdvertisementLenient(b []byte) *Advertisement {
+	adv, _ := ParseAdvertisement(b)
+	return adv
+}
+
+// uuid128 formats a 128 bit UUID, which advertisements carry little endian.
+func uuid128(le []byte) string {
+	be := make([]byte, 16)
+	for i := range be {
+		be[i] = le[15-i]
+	}
+	s := hex.EncodeToString(be)
+	return NormalizeUUID(s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:])
+}
+
+// bluetoothBaseUUID is the UUID that 16 and 32 bit UUIDs are shorthand for.
+const bluetoothBaseUUID = "-0000-1000-8000-00805F9B34FB"
+
+// NormalizeUUID returns a service UUID in the form [Advertisement] uses: the 4 upper case hex
+// digits of a 16 bit UUID, such as "FEED", and upper case 128 bit UUIDs otherwise.
+func NormalizeUUID(s string) string {
+	s = strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X"))
+	if len(s) == 36 && strings.HasSuffix(s, bluetoothBaseUUID) && strings.HasPrefix(s, "0000") {
+		return s[4:8]
+	}
+	if len(s) < 4 && len(s) > 0 {
+		s = strings.Repeat("0", 4-len(s)) + s
+	}
+	return s
+}
+
+func (adv *Advertisement) addService(uuid string, data []byte) {
+	uuid = NormalizeUUID(uuid)
+	if !slices.Contains(adv.ServiceUUIDs, uuid) {
+		adv.ServiceUUIDs = append(adv.ServiceUUIDs, uuid)
+	}
+	if data != nil {
+		if adv.ServiceData == nil {
+			adv.ServiceData = make(map[string][]byte)
+		}
+		adv.ServiceData[uuid] = data
+	}
+}
+
+func (adv *Advertisement) addServices(uuids []string, data map[string]HexBytes) {
+	for _, u := range uuids {
+		adv.addService(u, nil)
+	}
+	keys := make([]string, 0, len(data))
+	for u := range data {
+		keys = append(keys, u)
+	}
+	slices.Sort(keys)
+	for _, u := range keys {
+		adv.addService(u, data[u])
+	}
+}
+
+func (adv *Advertisement) addManufacturerData(data map[string]HexBytes) {
+	for k, d := range data {
+		id, err := strconv.ParseUint(k, 0, 16)
+		if err != nil {
+			continue
+		}
+		if adv.ManufacturerData == nil {
+			adv.ManufacturerData = make(map[uint16][]byte)
+		}
+		adv.ManufacturerData[uint16(id)] = d
+	}
+}
+
+// Tracker is a kind of item tracker, recognised by what it advertises.
+type Tracker int
+
+const (
+	TrackerNone Tracker = iota
+	// TrackerFindMy is an AirTag or another accessory of Apple's Find My network.
+	TrackerFindMy
+	// TrackerTile is a Tile tracker.
+	TrackerTile
+	// TrackerSmartTag is a Samsung Galaxy SmartTag.
+	TrackerSmartTag
+)
+
+var trackerNames = map[Tracker]string{
+	TrackerNone:     "none",
+	TrackerFindMy:   "Find My",
+	TrackerTile:     "Tile",
+	TrackerSmartTag: "SmartTag",
+}
+
+func (t Tracker) String() string {
+	if name, ok := trackerNames[t]; ok {
+		return name
+	}
+	return "Tracker(" + strconv.Itoa(int(t)) + ")"
+}
+
+func (t Tracker) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+const (
+	companyApple = 0x004c
+
+	// appleOfflineFinding is the type of the Find My payload that AirTags and other
+	// accessories broadcast when away from their owner.
+	appleOfflineFinding = 0x12
+	// appleNearbyAirTag is the type an AirTag that isn't registered yet broadcasts, whose
+	// payload is of length appleAirTagLength.
+	appleNearbyAirTag = 0x07
+	appleAirTagLength = 0x19
+
+	uuidTileA    = "FEEC"
+	uuidTileB    = "FEED"
+	uuidSmartTag = "FD5A"
+)
+
+// Tracker returns the kind of tracker the advertisement comes from, or TrackerNone.
+func (adv *Advertisement) Tracker() Tracker {
+	if apple := adv.ManufacturerData[companyApple]; len(apple) >= 2 {
+		if apple[0] == appleOfflineFinding || (apple[0] == appleNearbyAirTag && apple[1] == appleAirTagLength) {
+			return TrackerFindMy
+		}
+	}
+	for _, u := range adv.ServiceUUIDs {
+		switch u {
+		case uuidTileA, uuidTileB:
+			return TrackerTile
+		case uuidSmartTag:
+			return TrackerSmartTag
+		}
+	}
+	return TrackerNone
+}
+
+// Advertisement returns what a Bluetooth or BTLE device advertised, or nil for other devices.
+func (d *Device) Advertisement() *Advertisement {
+	switch {
+	case d.Bluetooth != nil:
+		return d.Bluetooth.Advertisement()
+	case d.BTLE != nil:
+		return d.BTLE.Advertisement()
+	default:
+		return nil
+	}
+}
+
+// Tracker returns the kind of tracker a Bluetooth or BTLE device is, or TrackerNone.
+func (d *Device) Tracker() Tracker {
+	if adv := d.Advertisement(); adv != nil {
+		return adv.Tracker()
+	}
+	return TrackerNone
+}
@@ -0,0 +1,101 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testBluetoothData = `{
+	"kismet.device.base.macaddr": "DD:DD:DD:DD:DD:01",
+	"kismet.device.base.phyname": "Bluetooth",
+	"kismet.device.base.type": "BTLE",
+	"bluetooth.device": {
+		"bluetooth.device.service_uuid_vec": ["0000180f-0000-1000-8000-00805f9b34fb"],
+		"bluetooth.device.scan_data_bytes": "0a:09:4b:65:79:63:68:61:69:6e:73:02:0a:f4:07:ff:4c:00:12:19:10:00",
+		"bluetooth.device.txpower": -12,
+		"bluetooth.device.pathloss": 40,
+		"bluetooth.device.bonded": 0
+	}
+}`
+
+func TestParseBluetooth(t *testing.T) {
+	d, err := Parse([]byte(testBluetoothData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Bluetooth == nil || d.BTLE != nil {
+		t.Fatalf("expected only the bluetooth record to be decoded: %+v", d)
+	}
+	if _, ok := d.Unknown["bluetooth.device"]; ok {
+		t.Error("the decoded bluetooth record was kept as unknown too")
+	}
+	if _, ok := d.Bluetooth.Unknown["bluetooth.device.bonded"]; !ok {
+		t.Error("expected unmodelled bluetooth keys to be kept")
+	}
+
+	adv := d.Advertisement()
+	if adv.Name != "Keychains" || !adv.HasTxPower || adv.TxPower != -12 || adv.ServiceUUIDs[0] != "180F" {
+		t.Errorf("unexpected advertisement: %+v", adv)
+	}
+	if d.Tracker() != TrackerFindMy {
+		t.Errorf("expected a Find My tracker, got %s", d.Tracker())
+	}
+
+	b, err := Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equivalentJSON(t, b, []byte(testBluetoothData)) {
+		t.Fatalf("round trip lost data:\n%s", b)
+	}
+
+	// the bluetooth record of a device of another phy is left alone
+	wifi, err := Parse(bytes.Replace([]byte(testBluetoothData), []byte(`"Bluetooth"`), []byte(`"IEEE802.11"`), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wifi.Bluetooth != nil || wifi.Tracker() != TrackerNone {
+		t.Error("decoded the bluetooth record of a wifi device")
+	}
+	if _, ok := wifi.Unknown["bluetooth.device"]; !ok {
+		t.Error("expected the bluetooth record of a wifi device to be kept as unknown")
+	}
+}
+
+func TestTrackers(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		blob string
+		want Tracker
+	}{
+		{"tile", `{"kismet.device.base.phyname": "Bluetooth", "bluetooth.device": {"bluetooth.device.service_data_bytes": {"0xfeed": "0102"}}}`, TrackerTile},
+		{"smarttag", `{"kismet.device.base.phyname": "BTLE", "btle.device": {"btle.device.service_uuid_vec": ["FD5A"]}}`, TrackerSmartTag},
+		{"airtag", `{"kismet.device.base.phyname": "BTLE", "btle.device": {"btle.device.manuf_data": {"76": "0719050055"}}}`, TrackerFindMy},
+		{"handoff", `{"kismet.device.base.phyname": "BTLE", "btle.device": {"btle.device.manuf_data": {"76": "0c0e00"}}}`, TrackerNone},
+		{"wifi", testData, TrackerNone},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := Parse([]byte(tc.blob))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := d.Tracker(); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseAdvertisement(t *testing.T) {
+	// flags, a 128 bit service, then a structure running past the end
+	b := []byte{0x02, 0x01, 0x06, 0x11, 0x07,
+		0xfb, 0x34, 0x9b, 0x5f, 0x80, 0x00, 0x00, 0x80, 0x00, 0x10, 0x00, 0x00, 0x5a, 0xfd, 0x00, 0x00,
+		0x05, 0x09, 0x41}
+	adv, err := ParseAdvertisement(b)
+	if err == nil {
+		t.Error("expected the truncated structure to be reported")
+	}
+	if len(adv.ServiceUUIDs) != 1 || adv.ServiceUUIDs[0] != "FD5A" || adv.Tracker() != TrackerSmartTag {
+		t.Errorf("unexpected advertisement: %+v", adv)
+	}
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+)
+
+// DefaultChunkRows is the number of rowids copied per transaction when [MergeOptions.ChunkRows] is unset.
+const DefaultChunkRows = 50000
+
+// progressTable records the last rowid committed for every source table, so that an
+// interrupted merge can resume where it left off. It is dropped once a merge completes.
+const progressTable = "kismet2mdk_merge_progress"
+
+//goland:noinspection SqlNoDataSourceInspection
+const progressSchema = `CREATE TABLE IF NOT EXISTS ` + progressTable + ` (source TEXT, tbl TEXT, last_rowid INT, PRIMARY KEY(source, tbl));`
+
+// ChunkProgress is reported after every committed chunk of a table copy.
+type ChunkProgress struct {
+	Source string
+	Alias  string
+	Table  string
+	// Copied is the number of rows inserted by this chunk.
+	Copied int64
+	// LastRowID is the highest source rowid committed so far, out of MaxRowID.
+	LastRowID int64
+	MaxRowID  int64
+}
+
+func (cp ChunkProgress) String() string {
+	return fmt.Sprintf("%s.%s: +%d rows (rowid %d/%d)", cp.Alias, cp.Table, cp.Copied, cp.LastRowID, cp.MaxRowID)
+}
+
+// mergeConn is a source attached to the connection that its group is merged through.
+type mergeConn struct {
+	source string
+	alias  string
+	conn   *sql.Conn
+	retry  RetryPolicy
+	log    *slog.Logger
+}
+
+func newMergeConn(ctx context.Context, conn *sql.Conn, source, alias string, target *KismetDatabase) (*mergeConn, error) {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", source, err)
+	}
+	log := target.Logger().With("source", abs, "alias", alias)
+	rp := target.RetryPolicy()
+	if rp.Logger == nil {
+		rp.Logger = log
+	}
+	if _, err = conn.ExecContext(ctx, attachQuery(source, alias)); err != nil {
+		return nil, fmt.Errorf("failed to attach %s: %w", source, wrapSQLiteError(err))
+	}
+	return &mergeConn{source: abs, alias: alias, conn: conn, retry: rp, log: log}, nil
+}
+
+// Close detaches the source, leaving the connection to the rest of its group. It runs without
+// a context so that a cancelled merge still cleans up after itself.
+func (mc *mergeConn) Close() error {
+	if _, err := mc.conn.ExecContext(context.Background(), detachQuery(mc.alias)); err != nil {
+		return fmt.Errorf("failed to detach %s: %w", mc.alias, wrapSQLiteError(err))
+	}
+	return nil
+}
+
+//goland:noinspection SqlResolve
+func (mc *mergeConn) rowidBounds(ctx context.Context, table string) (lastRowID, maxRowID int64, err error) {
+	var minRowID int64
+	if err = mc.conn.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT ifnull(min(rowid), 1), ifnull(max(rowid), 0) FROM %s.'%s'", mc.alias, table),
+	).Scan(&minRowID, &maxRowID); err != nil {
+		return 0, 0, fmt.Errorf("failed to get rowid bounds of %s.%s: %w", mc.alias, table, wrapSQLiteError(err))
+	}
+
+	err = mc.conn.QueryRowContext(ctx,
+		"SELECT last_rowid FROM "+progressTable+" WHERE source = ? AND tbl = ?", mc.source, table,
+	).Scan(&lastRowID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return minRowID - 1, maxRowID, nil
+	case err != nil:
+		return 0, 0, fmt.Errorf("failed to read merge progress of %s.%s: %w", mc.alias, table, wrapSQLiteError(err))
+	default:
+		return lastRowID, maxRowID, nil
+	}
+}
+
+// copyChunk copies the rows of table with a rowid in (after, upTo] and records
+// upTo as committed in the same transaction, then checkpoints the WAL.
+//
+//goland:noinspection SqlResolve
+func (mc *mergeConn) copyChunk(ctx context.Context, table string, after, upTo int64) (int64, error) {
+	tx, err := mc.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapSQLiteError(err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		fmt.Sprintf("INSERT OR IGNORE INTO '%s' SELECT * FROM %s.'%s' WHERE rowid > ? AND rowid <= ?", table, mc.alias, table),
+		after, upTo,
+	)
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			"INSERT OR REPLACE INTO "+progressTable+" (source, tbl, last_rowid) VALUES (?, ?, ?)",
+			mc.source, table, upTo,
+		)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, wrapSQLiteError(err)
+	}
+
+	// the chunk is already committed, so a failed checkpoint must not cause it to be
+	// retried; the WAL is simply checkpointed along with the next chunk instead.
+	_, _ = mc.conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+
+	copied, _ := res.RowsAffected()
+	return copied, nil
+}
+
+func (kdb *KismetDatabase) ensureProgressTable(ctx context.Context) error {
+	if err := kdb.exec(ctx, "merge progress", progressSchema); err != nil {
+		return fmt.Errorf("failed to create merge progress table: %w", err)
+	}
+	return nil
+}
+
+func (kdb *KismetDatabase) dropProgressTable(ctx context.Context) error {
+	//goland:noinspection SqlResolve
+	if err := kdb.exec(ctx, "merge progress", "DROP TABLE IF EXISTS "+progressTable); err != nil {
+		return fmt.Errorf("failed to drop merge progress table: %w", err)
+	}
+	return nil
+}
@@ -1,10 +1,13 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 	"sync"
 
 	_ "github.com/glebarez/go-sqlite"
@@ -25,29 +28,38 @@ CREATE TABLE IF NOT EXISTS snapshots (ts_sec INT, ts_usec INT, lat REAL, lon REA
 // intentionally partial list
 var kismetTables = []string{"KISMET", "devices", "packets", "data", "alerts", "messages"}
 
-//goland:noinspection SqlNoDataSourceInspection
-func tableExistsQuery(name string) string {
-	return fmt.Sprintf("SELECT name FROM sqlite_master WHERE type='table' AND name='%s';", name)
-}
+// DefaultMaxReaders is the size of a [KismetDatabase]'s read pool, see [KismetDatabase.SetMaxReaders].
+const DefaultMaxReaders = 4
 
+// KismetDatabase is a Kismet log opened for reading and writing. Writes are serialized
+// through a single connection, so connection level pragmas stick and writers never contend
+// with each other, while reads go through a separate pool of read-only connections that,
+// with WAL enabled, proceed alongside a running write.
 type KismetDatabase struct {
 	path string
+	// conn is the single write connection.
 	conn *sql.DB
+	// reader is the pool of query_only connections used by read operations.
+	reader *sql.DB
 
 	pragma map[Pragma]string
 	mu     sync.Mutex
 
 	newTmpDir string
+	ownTmpDir bool
+
+	retry  RetryPolicy
+	logger *slog.Logger
 }
 
 func (kdb *KismetDatabase) String() string {
 	return kdb.path
 }
 
-func (kdb *KismetDatabase) backupPragma(s Pragma) error {
+func (kdb *KismetDatabase) backupPragma(ctx context.Context, s Pragma) error {
 	var pragma string
-	if err := kdb.conn.QueryRow("PRAGMA " + string(s)).Scan(&pragma); err != nil {
-		return fmt.Errorf("failed to backup pragma %s: %w", s, err)
+	if err := kdb.conn.QueryRowContext(ctx, "PRAGMA "+string(s)).Scan(&pragma); err != nil {
+		return fmt.Errorf("failed to backup pragma %s: %w", s, wrapSQLiteError(err))
 	}
 	kdb.mu.Lock()
 	kdb.pragma[s] = pragma
@@ -72,22 +84,29 @@ func (kdb *KismetDatabase) clearPragmaBackup(s Pragma) {
 }
 
 func CheckKismetSchema(db *sql.DB) error {
-	if err := db.Ping(); err != nil {
-		return err
-	}
+	return CheckKismetSchemaCtx(context.Background(), db)
+}
 
-	var tErrs = make([]error, 0, len(kismetTables))
+// CheckKismetSchemaCtx is [CheckKismetSchema] with a context.
+func CheckKismetSchemaCtx(ctx context.Context, db *sql.DB) error {
+	if err := db.PingContext(ctx); err != nil {
+		return wrapSQLiteError(err)
+	}
 
-	for _, t := range kismetTables {
-		if _, err := db.Exec(tableExistsQuery(t)); err != nil {
-			tErrs = append(tErrs, fmt.Errorf("missing table %s in kismet database: %w", t, err))
-		}
+	if err := missingTables(ctx, db, kismetTables); err != nil {
+		return fmt.Errorf("kismet database: %w", err)
 	}
 
-	return errors.Join(tErrs...)
+	return nil
 }
 
 func OpenKismetDatabase(path string) (*KismetDatabase, error) {
+	return OpenKismetDatabaseCtx(context.Background(), path)
+}
+
+// OpenKismetDatabaseCtx is [OpenKismetDatabase] with a context, which only bounds opening the
+// database and writing its schema; it is not kept for later operations.
+func OpenKismetDatabaseCtx(ctx context.Context, path string) (*KismetDatabase, error) {
 	stat, err := os.Stat(path)
 
 	switch {
@@ -104,36 +123,169 @@ func OpenKismetDatabase(path string) (*KismetDatabase, error) {
 	kdb := new(KismetDatabase)
 	kdb.pragma = make(map[Pragma]string)
 	kdb.path = path
+	kdb.retry = DefaultRetryPolicy
 	if kdb.conn, err = sql.Open("sqlite", path); err != nil {
-		return nil, fmt.Errorf("sql: %w", err)
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
 	}
+	kdb.conn.SetMaxOpenConns(1)
 
-	if err = kdb.conn.Ping(); err != nil {
-		return nil, fmt.Errorf("sql ping: %w", err)
+	if err = kdb.conn.PingContext(ctx); err != nil {
+		_ = kdb.conn.Close()
+		return nil, fmt.Errorf("sql ping: %w", wrapSQLiteError(err))
 	}
 
-	res, err := kdb.conn.Exec(kismetSchema)
+	// CREATE TABLE doesn't count as affecting rows, so look before writing the schema
+	fresh := missingTables(ctx, kdb.conn, kismetTables) != nil
+
+	err = kdb.retry.Do(ctx, "schema", func() error {
+		_, execErr := kdb.conn.ExecContext(ctx, kismetSchema)
+		return wrapSQLiteError(execErr)
+	})
 	if err != nil {
+		_ = kdb.conn.Close()
 		return nil, fmt.Errorf("sql, failed to assure schema: %w", err)
 	}
 
-	if affected, _ := res.RowsAffected(); affected > 0 {
-		println("wrote schema to db at", path)
+	if fresh {
+		kdb.Logger().Info("wrote schema")
+	}
+
+	// opened after the schema, so that readers never see a half written one
+	if kdb.reader, err = sql.Open("sqlite", path+"?_pragma=query_only(1)"); err != nil {
+		_ = kdb.conn.Close()
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
 	}
+	kdb.reader.SetMaxOpenConns(DefaultMaxReaders)
+	kdb.reader.SetMaxIdleConns(DefaultMaxReaders)
 
 	return kdb, nil
 }
 
-func (kdb *KismetDatabase) tables() (*sql.Rows, error) {
-	rows, err := kdb.conn.Query("SELECT name FROM sqlite_master WHERE type='table'")
+// OpenKismetDatabaseReadOnly opens an existing Kismet log without writing to it: unlike
+// [OpenKismetDatabase], the schema isn't written but checked, and every connection is opened
+// read-only, so write operations fail.
+func OpenKismetDatabaseReadOnly(path string) (*KismetDatabase, error) {
+	return OpenKismetDatabaseReadOnlyCtx(context.Background(), path)
+}
+
+// OpenKismetDatabaseReadOnlyCtx is [OpenKismetDatabaseReadOnly] with a context, which only
+// bounds opening the database and checking its schema.
+func OpenKismetDatabaseReadOnlyCtx(ctx context.Context, path string) (*KismetDatabase, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("kismet db access failure: %w", err)
+	}
+	uri, err := readOnlyURI(path)
 	if err != nil {
-		err = fmt.Errorf("failed to get tables for '%s': %w", kdb.path, err)
+		return nil, err
+	}
+
+	kdb := new(KismetDatabase)
+	kdb.pragma = make(map[Pragma]string)
+	kdb.path = path
+	kdb.retry = DefaultRetryPolicy
+	if kdb.conn, err = sql.Open("sqlite", uri); err != nil {
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
+	}
+	kdb.conn.SetMaxOpenConns(1)
+	if kdb.reader, err = sql.Open("sqlite", uri); err != nil {
+		_ = kdb.conn.Close()
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
+	}
+	kdb.reader.SetMaxOpenConns(DefaultMaxReaders)
+	kdb.reader.SetMaxIdleConns(DefaultMaxReaders)
+
+	if err = CheckKismetSchemaCtx(ctx, kdb.reader); err != nil {
+		_ = kdb.Close()
+		return nil, fmt.Errorf("%s does not appear to be a valid kismet database: %w", path, err)
+	}
+
+	return kdb, nil
+}
+
+// SetMaxReaders bounds the number of read-only connections used by read operations.
+// Reads beyond that wait for a connection to be released.
+func (kdb *KismetDatabase) SetMaxReaders(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid number of readers: %d", n)
+	}
+	kdb.reader.SetMaxOpenConns(n)
+	kdb.reader.SetMaxIdleConns(n)
+	return nil
+}
+
+// Query runs a read-only query against the database on one of its read connections,
+// so it does not wait on, or hold up, writes such as a running merge.
+func (kdb *KismetDatabase) Query(query string, args ...any) (*sql.Rows, error) {
+	return kdb.QueryCtx(context.Background(), query, args...)
+}
+
+// QueryCtx is [KismetDatabase.Query] with a context.
+func (kdb *KismetDatabase) QueryCtx(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := kdb.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		err = fmt.Errorf("failed to query '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+	return rows, err
+}
+
+func (kdb *KismetDatabase) tables(ctx context.Context) (*sql.Rows, error) {
+	rows, err := kdb.reader.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table'")
+	if err != nil {
+		err = fmt.Errorf("failed to get tables for '%s': %w", kdb.path, wrapSQLiteError(err))
 	}
 	return rows, err
 }
 
+// maxAttachProbe is SQLite's compile-time ceiling for SQLITE_MAX_ATTACHED.
+const maxAttachProbe = 125
+
+// AttachLimit reports how many databases can be attached to the write connection, which
+// merges attach their sources to, found by attaching in-memory databases until SQLite refuses.
+func (kdb *KismetDatabase) AttachLimit() (int, error) {
+	return kdb.AttachLimitCtx(context.Background())
+}
+
+// AttachLimitCtx is [KismetDatabase.AttachLimit] with a context.
+func (kdb *KismetDatabase) AttachLimitCtx(ctx context.Context) (int, error) {
+	return attachLimit(ctx, kdb.conn)
+}
+
+func attachLimit(ctx context.Context, db *sql.DB) (int, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve connection: %w", wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	limit := 0
+	for ; limit < maxAttachProbe; limit++ {
+		if _, err = conn.ExecContext(ctx, attachQuery(":memory:", "probe"+strconv.Itoa(limit))); err != nil {
+			break
+		}
+	}
+
+	for i := 0; i < limit; i++ {
+		if _, err = conn.ExecContext(ctx, detachQuery("probe"+strconv.Itoa(i))); err != nil {
+			return 0, fmt.Errorf("failed to detach attach limit probe: %w", wrapSQLiteError(err))
+		}
+	}
+
+	if limit == 0 {
+		return 0, errors.New("unable to attach any databases")
+	}
+
+	return limit, nil
+}
+
 func (kdb *KismetDatabase) Vacuum() error {
-	_, err := kdb.conn.Exec("VACUUM")
+	return kdb.VacuumCtx(context.Background())
+}
+
+// VacuumCtx is [KismetDatabase.Vacuum] with a context.
+func (kdb *KismetDatabase) VacuumCtx(ctx context.Context) error {
+	err := kdb.exec(ctx, "vacuum", "VACUUM")
 	if err != nil {
 		err = fmt.Errorf("failed to vacuum '%s': %w", kdb.path, err)
 	}
@@ -141,22 +293,130 @@ func (kdb *KismetDatabase) Vacuum() error {
 }
 
 func (kdb *KismetDatabase) Analyze() error {
-	_, err := kdb.conn.Exec("ANALYZE")
+	return kdb.AnalyzeCtx(context.Background())
+}
+
+// AnalyzeCtx is [KismetDatabase.Analyze] with a context.
+func (kdb *KismetDatabase) AnalyzeCtx(ctx context.Context) error {
+	err := kdb.exec(ctx, "analyze", "ANALYZE")
 	if err != nil {
 		err = fmt.Errorf("failed to analyze '%s': %w", kdb.path, err)
 	}
 	return err
 }
 
+// VacuumInto writes a compacted copy of the database to path, leaving the original untouched.
+func (kdb *KismetDatabase) VacuumInto(path string) error {
+	return kdb.VacuumIntoCtx(context.Background(), path)
+}
+
+// VacuumIntoCtx is [KismetDatabase.VacuumInto] with a context.
+func (kdb *KismetDatabase) VacuumIntoCtx(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("refusing to vacuum '%s' into existing file '%s'", kdb.path, path)
+	}
+	err := kdb.exec(ctx, "vacuum into", "VACUUM INTO ?", path)
+	if err != nil {
+		err = fmt.Errorf("failed to vacuum '%s' into '%s': %w", kdb.path, path, err)
+	}
+	return err
+}
+
+// EnableIncrementalVacuum switches the database to auto_vacuum=INCREMENTAL.
+// SQLite only applies the change after a full VACUUM, so one is run if the mode was not already set.
+func (kdb *KismetDatabase) EnableIncrementalVacuum() error {
+	return kdb.EnableIncrementalVacuumCtx(context.Background())
+}
+
+// EnableIncrementalVacuumCtx is [KismetDatabase.EnableIncrementalVacuum] with a context.
+func (kdb *KismetDatabase) EnableIncrementalVacuumCtx(ctx context.Context) error {
+	var mode int
+	if err := kdb.conn.QueryRowContext(ctx, "PRAGMA "+PragmaAutoVacuum.String()).Scan(&mode); err != nil {
+		return fmt.Errorf("failed to read auto_vacuum for '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+	if mode == 2 {
+		return nil
+	}
+	if err := kdb.exec(ctx, "auto_vacuum", PragmaAutoVacuum.SetQuery("INCREMENTAL")); err != nil {
+		return fmt.Errorf("failed to set auto_vacuum for '%s': %w", kdb.path, err)
+	}
+	return kdb.VacuumCtx(ctx)
+}
+
+// IncrementalVacuum releases free pages back to the filesystem. It is a no-op unless
+// [KismetDatabase.EnableIncrementalVacuum] has been called at some point on the file.
+func (kdb *KismetDatabase) IncrementalVacuum() error {
+	return kdb.IncrementalVacuumCtx(context.Background())
+}
+
+// IncrementalVacuumCtx is [KismetDatabase.IncrementalVacuum] with a context.
+func (kdb *KismetDatabase) IncrementalVacuumCtx(ctx context.Context) error {
+	err := kdb.exec(ctx, "incremental vacuum", "PRAGMA incremental_vacuum")
+	if err != nil {
+		err = fmt.Errorf("failed to incrementally vacuum '%s': %w", kdb.path, err)
+	}
+	return err
+}
+
+// Optimize runs PRAGMA optimize, which analyzes only the tables that would benefit from it.
+func (kdb *KismetDatabase) Optimize() error {
+	return kdb.OptimizeCtx(context.Background())
+}
+
+// OptimizeCtx is [KismetDatabase.Optimize] with a context.
+func (kdb *KismetDatabase) OptimizeCtx(ctx context.Context) error {
+	err := kdb.exec(ctx, "optimize", "PRAGMA optimize")
+	if err != nil {
+		err = fmt.Errorf("failed to optimize '%s': %w", kdb.path, err)
+	}
+	return err
+}
+
+// Size returns the on-disk size of the database, including its write-ahead log if present.
+func (kdb *KismetDatabase) Size() (int64, error) {
+	return fileSize(kdb.path)
+}
+
+func fileSize(path string) (int64, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat '%s': %w", path, err)
+	}
+	size := stat.Size()
+	if wal, walErr := os.Stat(path + "-wal"); walErr == nil {
+		size += wal.Size()
+	}
+	return size, nil
+}
+
 func (kdb *KismetDatabase) Close() error {
-	if kdb.newTmpDir != "" {
+	if kdb.newTmpDir != "" && kdb.ownTmpDir {
 		defer func(td string) {
 			_ = os.RemoveAll(td)
 		}(kdb.newTmpDir)
 	}
-	err := kdb.conn.Close()
+	err := errors.Join(kdb.reader.Close(), kdb.conn.Close())
 	if err != nil {
-		err = fmt.Errorf("failed to close '%s': %w", kdb.path, err)
+		err = fmt.Errorf("failed to close '%s': %w", kdb.path, wrapSQLiteError(err))
 	}
 	return err
 }
+
+// CloseCtx is [KismetDatabase.Close] with a context. Closing waits for running queries to
+// finish; if ctx is done first, CloseCtx returns without waiting and the close completes
+// in the background.
+func (kdb *KismetDatabase) CloseCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to close '%s': %w", kdb.path, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- kdb.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("failed to close '%s': %w", kdb.path, ctx.Err())
+	}
+}
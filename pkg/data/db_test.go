@@ -1,8 +1,12 @@
 package data
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestOpenKismetDatabase(t *testing.T) {
@@ -38,3 +42,99 @@ func TestOpenKismetDatabase(t *testing.T) {
 		}
 	})
 }
+
+func newTestDatabase(t *testing.T, name string) *KismetDatabase {
+	t.Helper()
+	db, err := OpenKismetDatabase(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestContextCancellation(t *testing.T) {
+	db := newTestDatabase(t, "ctx.kismet")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, op := range map[string]func() error{
+		"vacuum":   func() error { return db.VacuumCtx(ctx) },
+		"analyze":  func() error { return db.AnalyzeCtx(ctx) },
+		"optimize": func() error { return db.OptimizeCtx(ctx) },
+		"wal":      func() error { return db.EnableWALCtx(ctx, true) },
+		"tables": func() error {
+			_, err := db.TablesCtx(ctx)
+			return err
+		},
+		"open": func() error {
+			_, err := OpenKismetDatabaseCtx(ctx, filepath.Join(t.TempDir(), "never.kismet"))
+			return err
+		},
+		"close": func() error { return db.CloseCtx(ctx) },
+	} {
+		if err := op(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+
+	if _, err := db.TablesCtx(context.Background()); err != nil {
+		t.Errorf("database unusable after cancelled operations: %v", err)
+	}
+}
+
+func TestReadDuringWrite(t *testing.T) {
+	db := newTestDatabase(t, "rw.kismet")
+	if err := db.EnableWAL(true); err != nil {
+		t.Fatal(err)
+	}
+	seedTestDatabase(t, db, "00:11:22:33:44:55", 3)
+
+	// hold the write connection in an open transaction, as a merge does while copying a chunk
+	conn, err := db.conn.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//goland:noinspection SqlResolve
+	if _, err = tx.Exec("DELETE FROM packets"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+		_ = conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	related, err := db.FindRelatedMacsCtx(ctx, "00:11:22:33:44:55")
+	if err != nil {
+		t.Fatalf("read blocked by write: %v", err)
+	}
+	if len(related) != 1 {
+		t.Errorf("expected the uncommitted delete to be invisible to readers, got %v", related)
+	}
+
+	//goland:noinspection SqlResolve
+	rows, err := db.QueryCtx(ctx, "DELETE FROM devices")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		_ = rows.Close()
+	}
+	if err == nil {
+		t.Error("expected the read pool to refuse writes")
+	}
+
+	if err = db.SetMaxReaders(0); err == nil {
+		t.Error("expected zero readers to be rejected")
+	}
+}
@@ -1,5 +1,13 @@
 package data
 
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+
+	"github.com/bytedance/sonic"
+)
+
 func NewDevice() *Device {
 	d := new(Device)
 	d.Dot11 = Dot11{}
@@ -8,64 +16,79 @@ func NewDevice() *Device {
 }
 
 type SSID struct {
-	SSID                         string  `json:"dot11.advertisedssid.ssid"`
-	Len                          int     `json:"dot11.advertisedssid.ssidlen"`
-	Hash                         int     `json:"dot11.advertisedssid.ssid_hash"`
-	Beacon                       int     `json:"dot11.advertisedssid.beacon"`
-	ProbeResponse                int     `json:"dot11.advertisedssid.probe_response"`
-	Channel                      string  `json:"dot11.advertisedssid.channel"`
-	HtMode                       string  `json:"dot11.advertisedssid.ht_mode"`
-	HtCenter1                    int     `json:"dot11.advertisedssid.ht_center_1"`
-	HtCenter2                    int     `json:"dot11.advertisedssid.ht_center_2"`
-	FirstTime                    int     `json:"dot11.advertisedssid.first_time"`
-	LastTime                     int     `json:"dot11.advertisedssid.last_time"`
-	Cloaked                      int     `json:"dot11.advertisedssid.cloaked"`
-	CryptBitfield                int64   `json:"dot11.advertisedssid.crypt_bitfield"`
-	CryptSet                     int     `json:"dot11.advertisedssid.crypt_set"`
-	CryptString                  string  `json:"dot11.advertisedssid.crypt_string"`
-	Maxrate                      float64 `json:"dot11.advertisedssid.maxrate"`
-	Beaconrate                   int     `json:"dot11.advertisedssid.beaconrate"`
-	BeaconsSec                   int     `json:"dot11.advertisedssid.beacons_sec"`
-	IetagChecksum                int     `json:"dot11.advertisedssid.ietag_checksum"`
-	WpaMfpRequired               int     `json:"dot11.advertisedssid.wpa_mfp_required"`
-	WpaMfpSupported              int     `json:"dot11.advertisedssid.wpa_mfp_supported"`
-	Dot11RMobility               int     `json:"dot11.advertisedssid.dot11r_mobility"`
-	Dot11RMobilityDomainId       int     `json:"dot11.advertisedssid.dot11r_mobility_domain_id"`
-	Dot11EQbss                   int     `json:"dot11.advertisedssid.dot11e_qbss"`
-	Dot11EQbssStations           int     `json:"dot11.advertisedssid.dot11e_qbss_stations"`
-	Dot11EChannelUtilizationPerc float64 `json:"dot11.advertisedssid.dot11e_channel_utilization_perc"`
-	CcxTxpower                   int     `json:"dot11.advertisedssid.ccx_txpower"`
-	CiscoClientMfp               int     `json:"dot11.advertisedssid.cisco_client_mfp"`
-	AdvertisedTxpower            int     `json:"dot11.advertisedssid.advertised_txpower"`
-	Dot11DCountry                string  `json:"dot11.advertisedssid.dot11d_country"`
+	SSID                         string        `json:"dot11.advertisedssid.ssid"`
+	Len                          int           `json:"dot11.advertisedssid.ssidlen"`
+	Hash                         int           `json:"dot11.advertisedssid.ssid_hash"`
+	Beacon                       int           `json:"dot11.advertisedssid.beacon"`
+	ProbeResponse                int           `json:"dot11.advertisedssid.probe_response"`
+	Channel                      string        `json:"dot11.advertisedssid.channel"`
+	HtMode                       string        `json:"dot11.advertisedssid.ht_mode"`
+	HtCenter1                    int           `json:"dot11.advertisedssid.ht_center_1"`
+	HtCenter2                    int           `json:"dot11.advertisedssid.ht_center_2"`
+	FirstTime                    int           `json:"dot11.advertisedssid.first_time"`
+	LastTime                     int           `json:"dot11.advertisedssid.last_time"`
+	Cloaked                      int           `json:"dot11.advertisedssid.cloaked"`
+	CryptBitfield                uint64        `json:"dot11.advertisedssid.crypt_bitfield"` // Kismet's newer bitfield, kept raw as it isn't laid out like CryptSet
+	CryptSet                     Dot11CryptSet `json:"dot11.advertisedssid.crypt_set"`
+	CryptString                  string        `json:"dot11.advertisedssid.crypt_string"`
+	Maxrate                      float64       `json:"dot11.advertisedssid.maxrate"`
+	Beaconrate                   int           `json:"dot11.advertisedssid.beaconrate"`
+	BeaconsSec                   int           `json:"dot11.advertisedssid.beacons_sec"`
+	IetagChecksum                int           `json:"dot11.advertisedssid.ietag_checksum"`
+	WpaMfpRequired               int           `json:"dot11.advertisedssid.wpa_mfp_required"`
+	WpaMfpSupported              int           `json:"dot11.advertisedssid.wpa_mfp_supported"`
+	Dot11RMobility               int           `json:"dot11.advertisedssid.dot11r_mobility"`
+	Dot11RMobilityDomainId       int           `json:"dot11.advertisedssid.dot11r_mobility_domain_id"`
+	Dot11EQbss                   int           `json:"dot11.advertisedssid.dot11e_qbss"`
+	Dot11EQbssStations           int           `json:"dot11.advertisedssid.dot11e_qbss_stations"`
+	Dot11EChannelUtilizationPerc float64       `json:"dot11.advertisedssid.dot11e_channel_utilization_perc"`
+	CcxTxpower                   int           `json:"dot11.advertisedssid.ccx_txpower"`
+	CiscoClientMfp               int           `json:"dot11.advertisedssid.cisco_client_mfp"`
+	AdvertisedTxpower            int           `json:"dot11.advertisedssid.advertised_txpower"`
+	Dot11DCountry                string        `json:"dot11.advertisedssid.dot11d_country"`
+
+	// Unknown holds the keys of the SSID record that SSID doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type ssid SSID
+
+func (s *SSID) UnmarshalJSON(b []byte) (err error) {
+	s.Unknown, s.known, err = decodeLossless(b, (*ssid)(s))
+	return err
+}
+
+func (s SSID) MarshalJSON() ([]byte, error) {
+	return encodeLossless(ssid(s), s.Unknown, s.known)
 }
 
 type Dot11 struct {
-	Typeset                int    `json:"dot11.device.typeset"`
-	NumClientAps           int    `json:"dot11.device.num_client_aps"`
-	NumAdvertisedSsids     int    `json:"dot11.device.num_advertised_ssids"`
-	NumRespondedSsids      int    `json:"dot11.device.num_responded_ssids"`
-	NumProbedSsids         int    `json:"dot11.device.num_probed_ssids"`
-	NumAssociatedClients   int    `json:"dot11.device.num_associated_clients"`
-	ClientDisconnects      int    `json:"dot11.device.client_disconnects"`
-	ClientDisconnectsLast  int    `json:"dot11.device.client_disconnects_last"`
-	LastSequence           int    `json:"dot11.device.last_sequence"`
-	BssTimestamp           int64  `json:"dot11.device.bss_timestamp"`
-	NumFragments           int    `json:"dot11.device.num_fragments"`
-	NumRetries             int    `json:"dot11.device.num_retries"`
-	Datasize               int    `json:"dot11.device.datasize"`
-	DatasizeRetry          int    `json:"dot11.device.datasize_retry"`
-	LastBeaconTimestamp    int    `json:"dot11.device.last_beacon_timestamp"`
-	WpsM3Count             int    `json:"dot11.device.wps_m3_count"`
-	WpsM3Last              int    `json:"dot11.device.wps_m3_last"`
-	MinTxPower             int    `json:"dot11.device.min_tx_power"`
-	MaxTxPower             int    `json:"dot11.device.max_tx_power"`
-	LinkMeasurementCapable int    `json:"dot11.device.link_measurement_capable"`
-	NeighborReportCapable  int    `json:"dot11.device.neighbor_report_capable"`
-	BeaconFingerprint      int    `json:"dot11.device.beacon_fingerprint"`
-	ProbeFingerprint       int    `json:"dot11.device.probe_fingerprint"`
-	ResponseFingerprint    int    `json:"dot11.device.response_fingerprint"`
-	LastBssid              string `json:"dot11.device.last_bssid"`
+	Typeset                Dot11TypeSet `json:"dot11.device.typeset"`
+	NumClientAps           int          `json:"dot11.device.num_client_aps"`
+	NumAdvertisedSsids     int          `json:"dot11.device.num_advertised_ssids"`
+	NumRespondedSsids      int          `json:"dot11.device.num_responded_ssids"`
+	NumProbedSsids         int          `json:"dot11.device.num_probed_ssids"`
+	NumAssociatedClients   int          `json:"dot11.device.num_associated_clients"`
+	ClientDisconnects      int          `json:"dot11.device.client_disconnects"`
+	ClientDisconnectsLast  int          `json:"dot11.device.client_disconnects_last"`
+	LastSequence           int          `json:"dot11.device.last_sequence"`
+	BssTimestamp           int64        `json:"dot11.device.bss_timestamp"`
+	NumFragments           int          `json:"dot11.device.num_fragments"`
+	NumRetries             int          `json:"dot11.device.num_retries"`
+	Datasize               int          `json:"dot11.device.datasize"`
+	DatasizeRetry          int          `json:"dot11.device.datasize_retry"`
+	LastBeaconTimestamp    int          `json:"dot11.device.last_beacon_timestamp"`
+	WpsM3Count             int          `json:"dot11.device.wps_m3_count"`
+	WpsM3Last              int          `json:"dot11.device.wps_m3_last"`
+	MinTxPower             int          `json:"dot11.device.min_tx_power"`
+	MaxTxPower             int          `json:"dot11.device.max_tx_power"`
+	LinkMeasurementCapable int          `json:"dot11.device.link_measurement_capable"`
+	NeighborReportCapable  int          `json:"dot11.device.neighbor_report_capable"`
+	BeaconFingerprint      int          `json:"dot11.device.beacon_fingerprint"`
+	ProbeFingerprint       int          `json:"dot11.device.probe_fingerprint"`
+	ResponseFingerprint    int          `json:"dot11.device.response_fingerprint"`
+	LastBssid              string       `json:"dot11.device.last_bssid"`
 	/*
 		"dot11.device.associated_client_map": {
 		      	"00:00:00:E2:00:35": "0700000D00270204_3E5AE00B1F72",
@@ -74,36 +97,77 @@ type Dot11 struct {
 		},
 	*/
 	AssociatedClientMap    map[string]string `json:"dot11.device.associated_client_map"`
-	AdvertisedSsidMap      []SSID            `json:"dot11.device.advertised_ssid_map"`
+	AdvertisedSsidMap      SSIDs             `json:"dot11.device.advertised_ssid_map"`
 	LastBeaconedSsidRecord SSID              `json:"dot11.device.last_beaconed_ssid_record"`
+
+	// Unknown holds the keys of the dot11 record that Dot11 doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+// SSIDs is a list of SSID records. Older Kismet releases log it as a map keyed by SSID hash,
+// which is decoded into a list in key order.
+type SSIDs []SSID
+
+func (ss *SSIDs) UnmarshalJSON(b []byte) error {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) == 0 || trimmed[0] != '{' {
+		return sonic.Unmarshal(b, (*[]SSID)(ss))
+	}
+	byKey := make(map[string]SSID)
+	if err := sonic.Unmarshal(b, &byKey); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	*ss = make(SSIDs, 0, len(keys))
+	for _, k := range keys {
+		*ss = append(*ss, byKey[k])
+	}
+	return nil
+}
+
+type dot11 Dot11
+
+func (d11 *Dot11) UnmarshalJSON(b []byte) (err error) {
+	d11.Unknown, d11.known, err = decodeLossless(b, (*dot11)(d11))
+	return err
+}
+
+func (d11 Dot11) MarshalJSON() ([]byte, error) {
+	return encodeLossless(dot11(d11), d11.Unknown, d11.known)
 }
 
 type Device struct {
-	Dot11               Dot11  `json:"dot11.device"`
-	BaseKey             string `json:"kismet.device.base.key"`
-	BaseMacaddr         string `json:"kismet.device.base.macaddr"`
-	BaseRelatedDevices  any    // TODO: wat schema dis, Dot11?
-	BaseName            string `json:"kismet.device.base.name"`
-	BaseCommonname      string `json:"kismet.device.base.commonname"`
-	ServerUuid          string `json:"kismet.server.uuid"`
-	BaseBasicTypeSet    int    `json:"kismet.device.base.basic_type_set"`
-	BaseCrypt           string `json:"kismet.device.base.crypt"`
-	BaseBasicCryptSet   int    `json:"kismet.device.base.basic_crypt_set"`
-	BaseFirstTime       int    `json:"kismet.device.base.first_time"`
-	BaseLastTime        int    `json:"kismet.device.base.last_time"`
-	BaseModTime         int    `json:"kismet.device.base.mod_time"`
-	BasePacketsTotal    int    `json:"kismet.device.base.packets.total"`
-	BasePacketsRxTotal  int    `json:"kismet.device.base.packets.rx_total"`
-	BasePacketsTxTotal  int    `json:"kismet.device.base.packets.tx_total"`
-	BasePacketsLlc      int    `json:"kismet.device.base.packets.llc"`
-	BasePacketsError    int    `json:"kismet.device.base.packets.error"`
-	BasePacketsData     int    `json:"kismet.device.base.packets.data"`
-	BasePacketsCrypt    int    `json:"kismet.device.base.packets.crypt"`
-	BasePacketsFiltered int    `json:"kismet.device.base.packets.filtered"`
-	BaseDatasize        int    `json:"kismet.device.base.datasize"`
-	BaseChannel         string `json:"kismet.device.base.channel"`
-	BaseFrequency       int    `json:"kismet.device.base.frequency"`
-	BaseFreqKhzMap      any    // TODO
+	Dot11       Dot11  `json:"dot11.device"`
+	BaseKey     string `json:"kismet.device.base.key"`
+	BaseMacaddr string `json:"kismet.device.base.macaddr"`
+	// BaseRelatedDevices maps each kind of relation, such as association, to the related devices.
+	BaseRelatedDevices  map[string]json.RawMessage `json:"kismet.device.base.related_devices"`
+	BaseName            string                     `json:"kismet.device.base.name"`
+	BaseCommonname      string                     `json:"kismet.device.base.commonname"`
+	ServerUuid          string                     `json:"kismet.server.uuid"`
+	BaseBasicTypeSet    BasicTypeSet               `json:"kismet.device.base.basic_type_set"`
+	BaseCrypt           string                     `json:"kismet.device.base.crypt"`
+	BaseBasicCryptSet   BasicCryptSet              `json:"kismet.device.base.basic_crypt_set"`
+	BaseFirstTime       int                        `json:"kismet.device.base.first_time"`
+	BaseLastTime        int                        `json:"kismet.device.base.last_time"`
+	BaseModTime         int                        `json:"kismet.device.base.mod_time"`
+	BasePacketsTotal    int                        `json:"kismet.device.base.packets.total"`
+	BasePacketsRxTotal  int                        `json:"kismet.device.base.packets.rx_total"`
+	BasePacketsTxTotal  int                        `json:"kismet.device.base.packets.tx_total"`
+	BasePacketsLlc      int                        `json:"kismet.device.base.packets.llc"`
+	BasePacketsError    int                        `json:"kismet.device.base.packets.error"`
+	BasePacketsData     int                        `json:"kismet.device.base.packets.data"`
+	BasePacketsCrypt    int                        `json:"kismet.device.base.packets.crypt"`
+	BasePacketsFiltered int                        `json:"kismet.device.base.packets.filtered"`
+	BaseDatasize        int                        `json:"kismet.device.base.datasize"`
+	BaseChannel         string                     `json:"kismet.device.base.channel"`
+	BaseFrequency       int                        `json:"kismet.device.base.frequency"`
+	// BaseFreqKhzMap counts the packets seen on each frequency, keyed by kHz.
+	BaseFreqKhzMap map[string]float64 `json:"kismet.device.base.freq_khz_map"`
 
 	BaseNumAlerts int `json:"kismet.device.base.num_alerts"`
 	BaseSeenby    []struct {
@@ -115,54 +179,150 @@ type Device struct {
 	BasePhyname string `json:"kismet.device.base.phyname"`
 	BaseManuf   string `json:"kismet.device.base.manuf"`
 	BaseSignal  struct {
-		CommonSignalType        string `json:"kismet.common.signal.type"`
-		CommonSignalLastSignal  int    `json:"kismet.common.signal.last_signal"`
-		CommonSignalLastNoise   int    `json:"kismet.common.signal.last_noise"`
-		CommonSignalMinSignal   int    `json:"kismet.common.signal.min_signal"`
-		CommonSignalMinNoise    int    `json:"kismet.common.signal.min_noise"`
-		CommonSignalMaxSignal   int    `json:"kismet.common.signal.max_signal"`
-		CommonSignalMaxNoise    int    `json:"kismet.common.signal.max_noise"`
-		CommonSignalMaxseenrate int    `json:"kismet.common.signal.maxseenrate"`
-		CommonSignalEncodingset int    `json:"kismet.common.signal.encodingset"`
-		CommonSignalCarrierset  int    `json:"kismet.common.signal.carrierset"`
-		CommonSignalSignalRrd   struct {
-			CommonRrdLastTime    int   `json:"kismet.common.rrd.last_time"`
-			CommonRrdSerialTime  int   `json:"kismet.common.rrd.serial_time"`
-			CommonRrdLastValue   int   `json:"kismet.common.rrd.last_value"`
-			CommonRrdLastValueN1 int   `json:"kismet.common.rrd.last_value_n1"`
-			CommonRrdMinuteVec   []int `json:"kismet.common.rrd.minute_vec"`
-			CommonRrdBlankVal    int   `json:"kismet.common.rrd.blank_val"`
-		} `json:"kismet.common.signal.signal_rrd"`
+		CommonSignalType        string      `json:"kismet.common.signal.type"`
+		CommonSignalLastSignal  int         `json:"kismet.common.signal.last_signal"`
+		CommonSignalLastNoise   int         `json:"kismet.common.signal.last_noise"`
+		CommonSignalMinSignal   int         `json:"kismet.common.signal.min_signal"`
+		CommonSignalMinNoise    int         `json:"kismet.common.signal.min_noise"`
+		CommonSignalMaxSignal   int         `json:"kismet.common.signal.max_signal"`
+		CommonSignalMaxNoise    int         `json:"kismet.common.signal.max_noise"`
+		CommonSignalMaxseenrate int         `json:"kismet.common.signal.maxseenrate"`
+		CommonSignalEncodingset EncodingSet `json:"kismet.common.signal.encodingset"`
+		CommonSignalCarrierset  CarrierSet  `json:"kismet.common.signal.carrierset"`
+		CommonSignalSignalRrd   MinuteRRD   `json:"kismet.common.signal.signal_rrd"`
 	} `json:"kismet.device.base.signal"`
-	BaseTxPacketsRrd struct {
-		CommonRrdLastTime    int       `json:"kismet.common.rrd.last_time"`
-		CommonRrdSerialTime  int       `json:"kismet.common.rrd.serial_time"`
-		CommonRrdLastValue   int       `json:"kismet.common.rrd.last_value"`
-		CommonRrdLastValueN1 int       `json:"kismet.common.rrd.last_value_n1"`
-		CommonRrdMinuteVec   []int     `json:"kismet.common.rrd.minute_vec"`
-		CommonRrdHourVec     []float64 `json:"kismet.common.rrd.hour_vec"`
-		CommonRrdDayVec      []float64 `json:"kismet.common.rrd.day_vec"`
-		CommonRrdBlankVal    int       `json:"kismet.common.rrd.blank_val"`
-	} `json:"kismet.device.base.tx_packets.rrd"`
-	BasePacketsRrd struct {
-		CommonRrdLastTime    int       `json:"kismet.common.rrd.last_time"`
-		CommonRrdSerialTime  int       `json:"kismet.common.rrd.serial_time"`
-		CommonRrdLastValue   int       `json:"kismet.common.rrd.last_value"`
-		CommonRrdLastValueN1 int       `json:"kismet.common.rrd.last_value_n1"`
-		CommonRrdMinuteVec   []int     `json:"kismet.common.rrd.minute_vec"`
-		CommonRrdHourVec     []float64 `json:"kismet.common.rrd.hour_vec"`
-		CommonRrdDayVec      []float64 `json:"kismet.common.rrd.day_vec"`
-		CommonRrdBlankVal    int       `json:"kismet.common.rrd.blank_val"`
-	} `json:"kismet.device.base.packets.rrd"`
-	BaseDatasizeRrd struct {
-		CommonRrdLastTime    int       `json:"kismet.common.rrd.last_time"`
-		CommonRrdSerialTime  int       `json:"kismet.common.rrd.serial_time"`
-		CommonRrdLastValue   int       `json:"kismet.common.rrd.last_value"`
-		CommonRrdLastValueN1 int       `json:"kismet.common.rrd.last_value_n1"`
-		CommonRrdMinuteVec   []int     `json:"kismet.common.rrd.minute_vec"`
-		CommonRrdHourVec     []float64 `json:"kismet.common.rrd.hour_vec"`
-		CommonRrdDayVec      []float64 `json:"kismet.common.rrd.day_vec"`
-		CommonRrdBlankVal    int       `json:"kismet.common.rrd.blank_val"`
-	} `json:"kismet.device.base.datasize.rrd"`
-	BaseType string `json:"kismet.device.base.type"`
+	BaseTxPacketsRrd RRD            `json:"kismet.device.base.tx_packets.rrd"`
+	BasePacketsRrd   RRD            `json:"kismet.device.base.packets.rrd"`
+	BaseDatasizeRrd  RRD            `json:"kismet.device.base.datasize.rrd"`
+	BaseType         string         `json:"kismet.device.base.type"`
+	BaseLocation     DeviceLocation `json:"kismet.device.base.location"`
+
+	// Bluetooth is the bluetooth.device record, only decoded for devices of [PhyBluetooth].
+	Bluetooth *Bluetooth `json:"-"`
+	// BTLE is the btle.device record, only decoded for devices of [PhyBTLE].
+	BTLE *BTLE `json:"-"`
+	// RTL433 is the rtl433.device record, only decoded for devices of [PhyRTL433].
+	RTL433 *RTL433 `json:"-"`
+	// ADSB is the adsb.device record, only decoded for devices of [PhyADSB].
+	ADSB *ADSB `json:"-"`
+	// AMR is the rtlamr.device record, only decoded for devices of [PhyAMR].
+	AMR *AMR `json:"-"`
+	// UAV is the uav.device record, only decoded for devices of [PhyUAV].
+	UAV *UAV `json:"-"`
+
+	// Vendor is the organization the MAC is registered to, or what Kismet logged as the
+	// manufacturer if it isn't registered. RandomizedMAC reports whether the MAC is locally
+	// administered, see [IsRandomizedMAC]. Both are set by [Device.Enrich], and aren't logged.
+	Vendor        string `json:"-"`
+	RandomizedMAC bool   `json:"-"`
+
+	// Unknown holds the keys of the device that Device doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+// RRD is a round robin database of Kismet's: the last minute by the second, the last hour
+// by the minute and the last day by the hour, see [RRD.Series].
+type RRD struct {
+	CommonRrdLastTime    int       `json:"kismet.common.rrd.last_time"`
+	CommonRrdSerialTime  int       `json:"kismet.common.rrd.serial_time"`
+	CommonRrdLastValue   int       `json:"kismet.common.rrd.last_value"`
+	CommonRrdLastValueN1 int       `json:"kismet.common.rrd.last_value_n1"`
+	CommonRrdMinuteVec   []int     `json:"kismet.common.rrd.minute_vec"`
+	CommonRrdHourVec     []float64 `json:"kismet.common.rrd.hour_vec"`
+	CommonRrdDayVec      []float64 `json:"kismet.common.rrd.day_vec"`
+	CommonRrdBlankVal    int       `json:"kismet.common.rrd.blank_val"`
+}
+
+// MinuteRRD is an [RRD] that only keeps the last minute, such as that of a device's signal.
+type MinuteRRD struct {
+	CommonRrdLastTime    int   `json:"kismet.common.rrd.last_time"`
+	CommonRrdSerialTime  int   `json:"kismet.common.rrd.serial_time"`
+	CommonRrdLastValue   int   `json:"kismet.common.rrd.last_value"`
+	CommonRrdLastValueN1 int   `json:"kismet.common.rrd.last_value_n1"`
+	CommonRrdMinuteVec   []int `json:"kismet.common.rrd.minute_vec"`
+	CommonRrdBlankVal    int   `json:"kismet.common.rrd.blank_val"`
+}
+
+// Location is a GPS fix as Kismet logs it.
+type Location struct {
+	// Geopoint is the position as [lon, lat].
+	Geopoint []float64 `json:"kismet.common.location.geopoint"`
+	Alt      float64   `json:"kismet.common.location.alt"`
+	Fix      int       `json:"kismet.common.location.fix"`
+
+	// Unknown holds the keys of the location that Location doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+// Lat returns the latitude, or 0 without a position.
+func (l Location) Lat() float64 {
+	if len(l.Geopoint) < 2 {
+		return 0
+	}
+	return l.Geopoint[1]
+}
+
+// Lon returns the longitude, or 0 without a position.
+func (l Location) Lon() float64 {
+	if len(l.Geopoint) < 2 {
+		return 0
+	}
+	return l.Geopoint[0]
+}
+
+type location Location
+
+func (l *Location) UnmarshalJSON(b []byte) (err error) {
+	l.Unknown, l.known, err = decodeLossless(b, (*location)(l))
+	return err
+}
+
+func (l Location) MarshalJSON() ([]byte, error) {
+	return encodeLossless(location(l), l.Unknown, l.known)
+}
+
+// DeviceLocation bounds where a device was seen from.
+type DeviceLocation struct {
+	MinLoc Location `json:"kismet.common.location.min_loc"`
+	MaxLoc Location `json:"kismet.common.location.max_loc"`
+	AvgLoc Location `json:"kismet.common.location.avg_loc"`
+
+	// Unknown holds the keys of the location that DeviceLocation doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type deviceLocation DeviceLocation
+
+func (dl *DeviceLocation) UnmarshalJSON(b []byte) (err error) {
+	dl.Unknown, dl.known, err = decodeLossless(b, (*deviceLocation)(dl))
+	return err
+}
+
+func (dl DeviceLocation) MarshalJSON() ([]byte, error) {
+	return encodeLossless(deviceLocation(dl), dl.Unknown, dl.known)
+}
+
+type device Device
+
+// UnmarshalJSON decodes a device, keeping what Device doesn't model so that
+// [Device.MarshalJSON] can write it back. The phy specific record is decoded according
+// to kismet.device.base.phyname.
+func (d *Device) UnmarshalJSON(b []byte) (err error) {
+	if d.Unknown, d.known, err = decodeLossless(b, (*device)(d)); err != nil {
+		return err
+	}
+	return d.decodePhy()
+}
+
+// MarshalJSON encodes the device as Kismet logs it. A parsed device that has not been
+// changed encodes to JSON equivalent to what it was parsed from.
+func (d Device) MarshalJSON() ([]byte, error) {
+	unknown, err := d.phyUnknown()
+	if err != nil {
+		return nil, err
+	}
+	return encodeLossless(device(d), unknown, d.known)
 }
@@ -1,9 +1,112 @@
 package data
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
 
-const testData = ``
+const testData = `{
+	"kismet.device.base.key": "4202770D00000000_AAAAAAAAAA01",
+	"kismet.device.base.macaddr": "AA:AA:AA:AA:AA:01",
+	"kismet.device.base.phyname": "IEEE802.11",
+	"kismet.device.base.name": "corp",
+	"kismet.device.base.type": "Wi-Fi AP",
+	"kismet.device.base.crypt": "WPA2",
+	"kismet.device.base.channel": "6",
+	"kismet.device.base.frequency": 2437000,
+	"kismet.device.base.freq_khz_map": {"2437000": 1234},
+	"kismet.device.base.related_devices": {"dot11_bssts_similar": {"4202770D00000000_AAAAAAAAAA02": "4202770D00000000_AAAAAAAAAA02"}},
+	"kismet.device.base.location": {"kismet.common.location.avg_loc": {"kismet.common.location.geopoint": [13.4, 52.5], "kismet.common.location.time_sec": 1700000000}},
+	"kismet.device.base.packet.bin.250": 12,
+	"kismet.device.base.signal": {
+		"kismet.common.signal.type": "dbm",
+		"kismet.common.signal.last_signal": -42,
+		"kismet.common.signal.peak_loc": {"kismet.common.location.alt": 35.5}
+	},
+	"kismet.device.base.packets.total": 18446744073709551,
+	"dot11.device": {
+		"dot11.device.last_bssid": "AA:AA:AA:AA:AA:01",
+		"dot11.device.wpa_handshake_list": [],
+		"dot11.device.advertised_ssid_map": [{
+			"dot11.advertisedssid.ssid": "corp",
+			"dot11.advertisedssid.crypt_bitfield": 1026,
+			"dot11.advertisedssid.wps_manuf": "Acme"
+		}],
+		"dot11.device.associated_client_map": {"CC:CC:CC:CC:CC:01": "4202770D00000000_CCCCCCCCCC01"}
+	}
+}`
+
+// equivalentJSON reports whether a and b decode to the same value.
+func equivalentJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var av, bv any
+	for _, c := range []struct {
+		b []byte
+		v *any
+	}{{a, &av}, {b, &bv}} {
+		dec := json.NewDecoder(bytes.NewReader(c.b))
+		dec.UseNumber()
+		if err := dec.Decode(c.v); err != nil {
+			t.Fatalf("bad json %s: %v", c.b, err)
+		}
+	}
+	return reflect.DeepEqual(av, bv)
+}
 
 func TestParse(t *testing.T) {
+	d, err := Parse([]byte(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.BaseName != "corp" || d.Dot11.AdvertisedSsidMap[0].CryptBitfield != 1026 || d.BaseFreqKhzMap["2437000"] != 1234 ||
+		d.BaseLocation.AvgLoc.Lat() != 52.5 {
+		t.Fatalf("unexpected device: %+v", d)
+	}
+	if _, ok := d.Unknown["kismet.device.base.packet.bin.250"]; !ok {
+		t.Error("expected unmodelled keys to be kept")
+	}
+	if _, ok := d.Dot11.AdvertisedSsidMap[0].Unknown["dot11.advertisedssid.wps_manuf"]; !ok {
+		t.Error("expected unmodelled ssid keys to be kept")
+	}
+
+	b, err := Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equivalentJSON(t, b, []byte(testData)) {
+		t.Fatalf("round trip lost data:\n%s", b)
+	}
+
+	d.BaseName = "guest"
+	d.Dot11.AdvertisedSsidMap[0].SSID = "guest"
+	if b, err = Encode(d); err != nil {
+		t.Fatal(err)
+	}
+	edited, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.BaseName != "guest" || edited.Dot11.AdvertisedSsidMap[0].SSID != "guest" {
+		t.Errorf("edits were not encoded: %s", b)
+	}
+	if _, ok := edited.Dot11.AdvertisedSsidMap[0].Unknown["dot11.advertisedssid.wps_manuf"]; !ok {
+		t.Error("editing an ssid dropped its unmodelled keys")
+	}
+	if _, ok := edited.Unknown["kismet.device.base.packet.bin.250"]; !ok {
+		t.Error("editing a device dropped its unmodelled keys")
+	}
 
+	if b, err = Encode(NewDevice()); err != nil {
+		t.Fatal(err)
+	}
+	fresh := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(b, &fresh); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fresh["kismet.device.base.macaddr"]; !ok {
+		t.Errorf("expected a new device to encode every field, got %s", b)
+	}
 }
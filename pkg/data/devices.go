@@ -0,0 +1,372 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GeoBox is an area bounded by latitude and longitude, in degrees.
+type GeoBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// DeviceQuery selects the devices returned by [KismetDatabase.Devices]. Zero fields don't filter.
+type DeviceQuery struct {
+	// PhyName selects devices of one phy, such as "IEEE802.11".
+	PhyName string
+	// MAC selects the devices with this MAC, one per phy at most.
+	MAC string
+	// Vendor selects devices whose MAC is registered to an organization whose name contains
+	// the given text, ignoring case, as per [OUIRegistryInUse].
+	Vendor string
+	// Randomized, if set, selects devices whose MAC is, or isn't, randomized. See [IsRandomizedMAC].
+	Randomized *bool
+	// Type selects devices of one type, such as "Wi-Fi AP".
+	Type string
+	// Manuf and Crypt select devices whose manufacturer or encryption contains the given text, ignoring case.
+	Manuf string
+	Crypt string
+	// TypeSet and CryptSet select devices whose basic type or encryption has all the given flags,
+	// such as BasicTypeAP or BasicCryptWeak.
+	TypeSet  BasicTypeSet
+	CryptSet BasicCryptSet
+	// Dot11Crypt selects 802.11 devices advertising a network whose crypt set is of the given
+	// kind, such as Dot11EncryptionWPA3SAE.
+	Dot11Crypt Dot11Encryption
+	// Channel selects devices last seen on this channel.
+	Channel string
+	// MinSignal and MaxSignal bound the strongest signal seen from a device, in dBm.
+	MinSignal int
+	MaxSignal int
+	// Since and Until select devices seen at some point in [Since, Until].
+	Since time.Time
+	Until time.Time
+	// Box selects devices whose average location lies within it. Devices without a location never do.
+	Box *GeoBox
+	// Limit caps the number of devices returned.
+	Limit int
+	// Workers is the number of devices decoded in parallel, which defaults to GOMAXPROCS.
+	Workers int
+}
+
+// deviceField returns the SQL that extracts a top level key from a device's JSON.
+func deviceField(key string) string {
+	// kismet stores the JSON as a BLOB, which SQLite would otherwise take for JSONB
+	return `json_extract(CAST(device AS TEXT), '$."` + key + `"')`
+}
+
+// query builds the SELECT for the devices matching dq, in rowid order.
+//
+//goland:noinspection SqlResolve
+func (dq *DeviceQuery) query() (string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+
+	if dq.MAC != "" {
+		mac, err := normalizeMAC(dq.MAC)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, "devmac = ?")
+		args = append(args, mac)
+	}
+
+	if dq.Vendor != "" {
+		// a MAC belongs to the longest block covering it, so those nested in a vendor's
+		// blocks are left out
+		in := []string{"0"}
+		for _, vb := range OUIRegistryInUse().vendorBlocks(dq.Vendor) {
+			clause := prefixIn(vb.owned, "IN", &args)
+			if len(vb.nested) > 0 {
+				clause += " AND " + prefixIn(vb.nested, "NOT IN", &args)
+			}
+			in = append(in, "("+clause+")")
+		}
+		where = append(where, "("+strings.Join(in, " OR ")+")")
+	}
+
+	if dq.Randomized != nil {
+		not := ""
+		if !*dq.Randomized {
+			not = "NOT "
+		}
+		where = append(where, not+"ifnull(upper(substr(devmac, 2, 1)) IN ('2', '6', 'A', 'E'), 0)")
+	}
+
+	for _, eq := range []struct{ column, value string }{
+		{"phyname", dq.PhyName},
+		{"type", dq.Type},
+		{deviceField("kismet.device.base.channel"), dq.Channel},
+	} {
+		if eq.value != "" {
+			where = append(where, eq.column+" = ?")
+			args = append(args, eq.value)
+		}
+	}
+
+	for _, in := range []struct{ column, value string }{
+		{deviceField("kismet.device.base.manuf"), dq.Manuf},
+		{deviceField("kismet.device.base.crypt"), dq.Crypt},
+	} {
+		if in.value != "" {
+			where = append(where, "instr(lower("+in.column+"), lower(?)) > 0")
+			args = append(args, in.value)
+		}
+	}
+
+	for _, flags := range []struct {
+		column string
+		value  uint64
+	}{
+		{deviceField("kismet.device.base.basic_type_set"), uint64(dq.TypeSet)},
+		{deviceField("kismet.device.base.basic_crypt_set"), uint64(dq.CryptSet)},
+	} {
+		if flags.value != 0 {
+			where = append(where, "(ifnull("+flags.column+", 0) & ?) = ?")
+			args = append(args, int64(flags.value), int64(flags.value))
+		}
+	}
+
+	if dq.Dot11Crypt != Dot11EncryptionAny {
+		cond, ok := dq.Dot11Crypt.sql(`ifnull(json_extract(value, '$."dot11.advertisedssid.crypt_set"'), 0)`)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid 802.11 encryption: %s", dq.Dot11Crypt)
+		}
+		where = append(where, `EXISTS (SELECT 1 FROM json_each(CAST(device AS TEXT), '$."dot11.device"."dot11.device.advertised_ssid_map"') WHERE `+cond+")")
+	}
+
+	if dq.MinSignal != 0 {
+		where = append(where, "strongest_signal >= ?")
+		args = append(args, dq.MinSignal)
+	}
+	if dq.MaxSignal != 0 {
+		where = append(where, "strongest_signal <= ?")
+		args = append(args, dq.MaxSignal)
+	}
+
+	if !dq.Since.IsZero() {
+		where = append(where, "last_time >= ?")
+		args = append(args, dq.Since.Unix())
+	}
+	if !dq.Until.IsZero() {
+		where = append(where, "first_time <= ?")
+		args = append(args, dq.Until.Unix())
+	}
+
+	if box := dq.Box; box != nil {
+		if box.MinLat > box.MaxLat || box.MinLon > box.MaxLon {
+			return "", nil, fmt.Errorf("invalid box: %+v", *box)
+		}
+		where = append(where, "avg_lat BETWEEN ? AND ?", "avg_lon BETWEEN ? AND ?", "NOT (avg_lat = 0 AND avg_lon = 0)")
+		args = append(args, box.MinLat, box.MaxLat, box.MinLon, box.MaxLon)
+	}
+
+	if dq.Limit < 0 {
+		return "", nil, fmt.Errorf("invalid limit: %d", dq.Limit)
+	}
+
+	query := "SELECT phyname, devmac, device FROM devices"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY rowid"
+	if dq.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(dq.Limit)
+	}
+
+	return query, args, nil
+}
+
+// prefixIn returns a condition on the devmac starting with one of prefixes, all of the same
+// length, appending them to args. The prefixes are bound as a single JSON array, as a broad
+// vendor can have more blocks than SQLite allows variables in a statement.
+func prefixIn(prefixes []string, op string, args *[]any) string {
+	b, _ := json.Marshal(prefixes)
+	*args = append(*args, string(b))
+	return fmt.Sprintf("upper(substr(devmac, 1, %d)) %s (SELECT value FROM json_each(?))", len(prefixes[0]), op)
+}
+
+type deviceResult struct {
+	device *Device
+	err    error
+}
+
+type deviceJob struct {
+	phy, mac string
+	blob     []byte
+	result   chan deviceResult
+}
+
+// DeviceIter streams the devices selected by a [DeviceQuery]. Devices are decoded by a
+// pool of workers, but still returned in the order they are stored in. At most a couple
+// of devices per worker are held in memory at any time. It must be closed once done with.
+type DeviceIter struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results chan chan deviceResult
+	device  *Device
+	err     error
+	closed  bool
+}
+
+// Devices runs dq against the database, a nil dq selecting every device. Decoding stops at
+// the first device that fails to parse, which is reported by [DeviceIter.Err].
+func (kdb *KismetDatabase) Devices(ctx context.Context, dq *DeviceQuery) (*DeviceIter, error) {
+	if dq == nil {
+		dq = &DeviceQuery{}
+	}
+
+	workers := dq.Workers
+	switch {
+	case workers < 0:
+		return nil, fmt.Errorf("invalid number of workers: %d", workers)
+	case workers == 0:
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	query, args, err := dq.query()
+	if err != nil {
+		return nil, fmt.Errorf("bad device query for '%s': %w", kdb.path, err)
+	}
+
+	rows, err := kdb.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+
+	produceCtx, cancel := context.WithCancel(ctx)
+	di := &DeviceIter{
+		ctx:     ctx,
+		cancel:  cancel,
+		results: make(chan chan deviceResult, 2*workers),
+	}
+
+	jobs := make(chan deviceJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				d, parseErr := Parse(job.blob)
+				if parseErr != nil {
+					parseErr = fmt.Errorf("%s: failed to parse %s %s: %w", kdb.path, job.phy, job.mac, parseErr)
+				}
+				job.result <- deviceResult{device: d, err: parseErr}
+			}
+		}()
+	}
+
+	// queue reports false once the iterator has been closed or ctx is done
+	queue := func(result chan deviceResult) bool {
+		select {
+		case di.results <- result:
+			return true
+		case <-produceCtx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(di.results)
+		defer func() {
+			_ = rows.Close()
+		}()
+		defer func() {
+			close(jobs)
+			wg.Wait()
+		}()
+
+		for rows.Next() {
+			job := deviceJob{result: make(chan deviceResult, 1)}
+			if scanErr := rows.Scan(&job.phy, &job.mac, &job.blob); scanErr != nil {
+				job.result <- deviceResult{err: fmt.Errorf("failed to scan device: %w", wrapSQLiteError(scanErr))}
+				queue(job.result)
+				return
+			}
+			// the result is queued before the job is handed out, so devices come out in order
+			if !queue(job.result) {
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-produceCtx.Done():
+				return
+			}
+		}
+
+		if rowsErr := rows.Err(); rowsErr != nil {
+			result := make(chan deviceResult, 1)
+			result <- deviceResult{err: fmt.Errorf("failed to read devices of '%s': %w", kdb.path, wrapSQLiteError(rowsErr))}
+			queue(result)
+		}
+	}()
+
+	return di, nil
+}
+
+// Next waits for the next device to be decoded, reporting false once the devices are
+// exhausted or an error occurs.
+func (di *DeviceIter) Next() bool {
+	if di.err != nil || di.closed {
+		return false
+	}
+	result, ok := <-di.results
+	if !ok {
+		di.err = di.ctx.Err()
+		return false
+	}
+	var r deviceResult
+	select {
+	case r = <-result:
+	case <-di.ctx.Done():
+		// the producer may have stopped before handing this device to a worker
+		r.err = di.ctx.Err()
+	}
+	if r.err != nil {
+		di.err = r.err
+		return false
+	}
+	di.device = r.device
+	return true
+}
+
+// Device returns the device decoded by the last call to Next.
+func (di *DeviceIter) Device() *Device {
+	return di.device
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (di *DeviceIter) Err() error {
+	return di.err
+}
+
+// Close stops decoding and releases the iterator's connection back to the read pool.
+func (di *DeviceIter) Close() error {
+	di.cancel()
+	for range di.results {
+	}
+	di.closed = true
+	return nil
+}
+
+// All drains the iterator into a slice and closes it.
+func (di *DeviceIter) All() ([]*Device, error) {
+	defer func() {
+		_ = di.Close()
+	}()
+	var all []*Device
+	for di.Next() {
+		all = append(all, di.Device())
+	}
+	return all, di.Err()
+}
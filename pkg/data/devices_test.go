@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDevices(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "devices.kismet")
+
+	// every third device is an AP with a location, the rest are clients
+	for i := 0; i < 60; i++ {
+		var (
+			mac      = fmt.Sprintf("00:11:22:33:44:%02X", i)
+			kind     = "Wi-Fi Client"
+			lat, lon float64
+			crypt    = "None"
+			typeSet  = BasicTypeClient
+			cryptSet BasicCryptSet
+		)
+		if i%3 == 0 {
+			kind, lat, lon, crypt = "Wi-Fi AP", 52.5+float64(i)/1000, 13.4, "WPA2-PSK"
+			typeSet, cryptSet = BasicTypeAP, BasicCryptEncrypted|BasicCryptL2
+		}
+		blob := fmt.Sprintf(`{"kismet.device.base.macaddr": %q, "kismet.device.base.manuf": "Acme, Inc.", "kismet.device.base.crypt": %q, "kismet.device.base.channel": "%d", `+
+			`"kismet.device.base.basic_type_set": %d, "kismet.device.base.basic_crypt_set": %d}`, mac, crypt, 1+i%11, typeSet, cryptSet)
+		//goland:noinspection SqlResolve
+		if _, err := db.conn.Exec(
+			"INSERT INTO devices (first_time, last_time, devkey, phyname, devmac, strongest_signal, avg_lat, avg_lon, type, device) VALUES (?, ?, ?, 'IEEE802.11', ?, ?, ?, ?, ?, ?)",
+			100+i, 200+i, mac+"_key", mac, -30-i, lat, lon, kind, []byte(blob),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		query *DeviceQuery
+		want  int
+	}{
+		"all":       {nil, 60},
+		"ordered":   {&DeviceQuery{Workers: 7}, 60},
+		"type":      {&DeviceQuery{Type: "Wi-Fi AP"}, 20},
+		"manuf":     {&DeviceQuery{Manuf: "acme"}, 60},
+		"crypt":     {&DeviceQuery{Crypt: "wpa2"}, 20},
+		"mac":       {&DeviceQuery{MAC: "00:11:22:33:44:0a"}, 1},
+		"type set":  {&DeviceQuery{TypeSet: BasicTypeAP}, 20},
+		"crypt set": {&DeviceQuery{CryptSet: BasicCryptL2 | BasicCryptEncrypted}, 20},
+		"weak":      {&DeviceQuery{CryptSet: BasicCryptL2 | BasicCryptWeak}, 0},
+		"channel":   {&DeviceQuery{Channel: "1"}, 6},
+		"signal":    {&DeviceQuery{MinSignal: -39, MaxSignal: -30}, 10},
+		"time":      {&DeviceQuery{Since: time.Unix(250, 0), Until: time.Unix(155, 0)}, 6},
+		"box":       {&DeviceQuery{Box: &GeoBox{MinLat: 52.5, MaxLat: 52.53, MinLon: 13, MaxLon: 14}}, 11},
+		"limit":     {&DeviceQuery{PhyName: "IEEE802.11", Limit: 5}, 5},
+		"other phy": {&DeviceQuery{PhyName: "Bluetooth"}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			iter, err := db.Devices(ctx, tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			devices, err := iter.All()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(devices) != tc.want {
+				t.Fatalf("expected %d devices, got %d", tc.want, len(devices))
+			}
+			for i := 1; i < len(devices); i++ {
+				if devices[i-1].BaseMacaddr >= devices[i].BaseMacaddr {
+					t.Fatalf("devices out of order: %s before %s", devices[i-1].BaseMacaddr, devices[i].BaseMacaddr)
+				}
+			}
+		})
+	}
+
+	t.Run("close early", func(t *testing.T) {
+		iter, err := db.Devices(ctx, &DeviceQuery{Workers: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !iter.Next() {
+			t.Fatal(iter.Err())
+		}
+		if err = iter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if iter.Next() {
+			t.Error("expected no devices after close")
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		iter, err := db.Devices(cctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		for iter.Next() {
+		}
+		_ = iter.Close()
+		if !errors.Is(iter.Err(), context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", iter.Err())
+		}
+	})
+
+	t.Run("bad device", func(t *testing.T) {
+		insertTestDevice(t, db, "IEEE802.11", "00:11:22:33:44:FF", "{not json")
+		iter, err := db.Devices(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		devices, err := iter.All()
+		if err == nil || len(devices) != 60 {
+			t.Errorf("expected 60 devices and a parse error, got %d and %v", len(devices), err)
+		}
+	})
+
+	if _, err := db.Devices(ctx, &DeviceQuery{Workers: -1}); err == nil {
+		t.Error("expected negative workers to be rejected")
+	}
+}
+
+func TestDevicesByDot11Crypt(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "dot11crypt.kismet")
+
+	sets := []Dot11CryptSet{
+		0,
+		Dot11CryptWPS,
+		Dot11CryptWEP | Dot11CryptWEP104,
+		Dot11CryptWEP | Dot11CryptWPA | Dot11CryptTKIP | Dot11CryptWPAMigration,
+		Dot11CryptWPA | Dot11CryptPSK | Dot11CryptAESCCM | Dot11CryptVersionWPA2,
+		Dot11CryptWPA | Dot11CryptSAE | Dot11CryptAESCCM | Dot11CryptVersionWPA3,
+		Dot11CryptWPA | Dot11CryptEAP | Dot11CryptPEAP | Dot11CryptAESCCM,
+		Dot11CryptOWE | Dot11CryptAESCCM,
+		Dot11CryptPPTP,
+	}
+	for i, cs := range sets {
+		mac := fmt.Sprintf("00:11:22:33:44:%02X", i)
+		ssids := fmt.Sprintf(`[{"dot11.advertisedssid.ssid": "net%d", "dot11.advertisedssid.crypt_set": %d}]`, i, cs)
+		if i%2 == 1 {
+			// older logs key advertised SSIDs by their hash
+			ssids = fmt.Sprintf(`{"%d": {"dot11.advertisedssid.ssid": "net%d", "dot11.advertisedssid.crypt_set": %d}}`, i, i, cs)
+		}
+		insertTestDevice(t, db, PhyDot11, mac, fmt.Sprintf(`{"kismet.device.base.macaddr": %q, "dot11.device": {"dot11.device.advertised_ssid_map": %s}}`, mac, ssids))
+	}
+	// a client, advertising no network at all
+	insertTestDevice(t, db, PhyDot11, "00:11:22:33:44:FF", `{"kismet.device.base.macaddr": "00:11:22:33:44:FF", "dot11.device": {}}`)
+
+	for de := range dot11EncryptionNames {
+		if de == Dot11EncryptionAny {
+			continue
+		}
+		t.Run(de.String(), func(t *testing.T) {
+			iter, err := db.Devices(ctx, &DeviceQuery{Dot11Crypt: de})
+			if err != nil {
+				t.Fatal(err)
+			}
+			devices, err := iter.All()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want int
+			for _, cs := range sets {
+				if de.Matches(cs) {
+					want++
+				}
+			}
+			if want == 0 || len(devices) != want {
+				t.Fatalf("expected %d devices, got %d", want, len(devices))
+			}
+			for _, d := range devices {
+				if cs := d.Dot11.AdvertisedSsidMap[0].CryptSet; !de.Matches(cs) {
+					t.Errorf("%s: unexpected crypt set %s", d.BaseMacaddr, cs)
+				}
+			}
+		})
+	}
+
+	if _, err := db.Devices(ctx, &DeviceQuery{Dot11Crypt: 99}); err == nil {
+		t.Error("expected an unknown encryption to be rejected")
+	}
+}
@@ -0,0 +1,323 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// DiffDevice identifies a device in a [DatabaseDiff].
+type DiffDevice struct {
+	Phy   string `json:"phy"`
+	MAC   string `json:"mac"`
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Manuf string `json:"manuf,omitempty"`
+	// Vendor and Randomized are the enrichment of the device, see [Device.Enrich].
+	Vendor     string `json:"vendor,omitempty"`
+	Randomized bool   `json:"randomized,omitempty"`
+}
+
+func (dd DiffDevice) String() string {
+	s := dd.Phy + " " + dd.MAC
+	if dd.Type != "" {
+		s += " [" + dd.Type + "]"
+	}
+	if dd.Name != "" {
+		s += " \"" + dd.Name + "\""
+	}
+	if dd.Vendor != "" {
+		s += " (" + dd.Vendor + ")"
+	} else if dd.Manuf != "" {
+		s += " (" + dd.Manuf + ")"
+	}
+	if dd.Randomized {
+		s += " randomized"
+	}
+	return s
+}
+
+// FieldChange is a single attribute that differs between two surveys.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// APChange lists what changed about an access point present in both surveys.
+type APChange struct {
+	DiffDevice
+	Changes []FieldChange `json:"changes"`
+}
+
+// AssociationChange lists the clients that joined or left an access point between two surveys.
+type AssociationChange struct {
+	DiffDevice
+	Joined []string `json:"joined,omitempty"`
+	Left   []string `json:"left,omitempty"`
+}
+
+// DatabaseDiff is the result of [DiffKismetDatabases].
+type DatabaseDiff struct {
+	Before       string              `json:"before"`
+	After        string              `json:"after"`
+	Appeared     []DiffDevice        `json:"appeared"`
+	Disappeared  []DiffDevice        `json:"disappeared"`
+	APs          []APChange          `json:"ap_changes"`
+	Associations []AssociationChange `json:"association_changes"`
+}
+
+// WriteText writes a human-readable report of the diff to w.
+func (dd *DatabaseDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("--- " + dd.Before + "\n+++ " + dd.After + "\n")
+
+	b.WriteString(fmt.Sprintf("\nappeared (%d):\n", len(dd.Appeared)))
+	for _, d := range dd.Appeared {
+		b.WriteString("  + " + d.String() + "\n")
+	}
+
+	b.WriteString(fmt.Sprintf("\ndisappeared (%d):\n", len(dd.Disappeared)))
+	for _, d := range dd.Disappeared {
+		b.WriteString("  - " + d.String() + "\n")
+	}
+
+	b.WriteString(fmt.Sprintf("\nchanged access points (%d):\n", len(dd.APs)))
+	for _, ap := range dd.APs {
+		b.WriteString("  ~ " + ap.String() + "\n")
+		for _, c := range ap.Changes {
+			b.WriteString(fmt.Sprintf("      %s: %q -> %q\n", c.Field, c.Before, c.After))
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("\nchanged associations (%d):\n", len(dd.Associations)))
+	for _, ac := range dd.Associations {
+		b.WriteString("  ~ " + ac.String() + "\n")
+		for _, c := range ac.Joined {
+			b.WriteString("      + " + c + "\n")
+		}
+		for _, c := range ac.Left {
+			b.WriteString("      - " + c + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func diffDevice(d *Device, phy, mac string) DiffDevice {
+	return DiffDevice{
+		Phy: phy, MAC: mac, Name: d.BaseCommonname, Type: d.BaseType, Manuf: d.BaseManuf,
+		Vendor: d.Vendor, Randomized: d.RandomizedMAC,
+	}
+}
+
+// ssidName labels an advertised SSID, including cloaked ones that don't carry a name.
+func ssidName(s SSID) string {
+	if s.SSID == "" || s.Cloaked != 0 {
+		return "<hidden>"
+	}
+	return s.SSID
+}
+
+func ssidCrypt(s SSID) string {
+	return fmt.Sprintf("%s (0x%x)", s.CryptString, s.CryptBitfield)
+}
+
+func ssidsByName(d *Device) map[string]SSID {
+	ssids := make(map[string]SSID, len(d.Dot11.AdvertisedSsidMap))
+	for _, s := range d.Dot11.AdvertisedSsidMap {
+		ssids[ssidName(s)] = s
+	}
+	return ssids
+}
+
+func diffAccessPoint(before, after *Device) []FieldChange {
+	var changes []FieldChange
+
+	if before.BaseChannel != after.BaseChannel {
+		changes = append(changes, FieldChange{Field: "channel", Before: before.BaseChannel, After: after.BaseChannel})
+	}
+
+	if before.BaseCrypt != after.BaseCrypt {
+		changes = append(changes, FieldChange{Field: "crypt", Before: before.BaseCrypt, After: after.BaseCrypt})
+	}
+
+	if before.BaseBasicCryptSet != after.BaseBasicCryptSet {
+		changes = append(changes, FieldChange{
+			Field:  "crypt_set",
+			Before: before.BaseBasicCryptSet.String(),
+			After:  after.BaseBasicCryptSet.String(),
+		})
+	}
+
+	beforeSSIDs, afterSSIDs := ssidsByName(before), ssidsByName(after)
+
+	beforeNames := sortedKeys(beforeSSIDs)
+	afterNames := sortedKeys(afterSSIDs)
+	if !slices.Equal(beforeNames, afterNames) {
+		changes = append(changes, FieldChange{
+			Field:  "ssids",
+			Before: strings.Join(beforeNames, ", "),
+			After:  strings.Join(afterNames, ", "),
+		})
+	}
+
+	for _, name := range beforeNames {
+		a, ok := afterSSIDs[name]
+		if !ok {
+			continue
+		}
+		b := beforeSSIDs[name]
+		if b.CryptString != a.CryptString || b.CryptBitfield != a.CryptBitfield {
+			changes = append(changes, FieldChange{Field: "crypt[" + name + "]", Before: ssidCrypt(b), After: ssidCrypt(a)})
+		}
+	}
+
+	return changes
+}
+
+func diffAssociations(before, after *Device) (joined, left []string) {
+	for client := range after.Dot11.AssociatedClientMap {
+		if _, ok := before.Dot11.AssociatedClientMap[client]; !ok {
+			joined = append(joined, client)
+		}
+	}
+	for client := range before.Dot11.AssociatedClientMap {
+		if _, ok := after.Dot11.AssociatedClientMap[client]; !ok {
+			left = append(left, client)
+		}
+	}
+	slices.Sort(joined)
+	slices.Sort(left)
+	return joined, left
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+type deviceID struct {
+	phy string
+	mac string
+}
+
+func sortedDeviceIDs(m map[deviceID]*Device) []deviceID {
+	ids := make([]deviceID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b deviceID) int {
+		if c := strings.Compare(a.phy, b.phy); c != 0 {
+			return c
+		}
+		return strings.Compare(a.mac, b.mac)
+	})
+	return ids
+}
+
+// loadDevices parses every device in kdb, keyed by phy and MAC. Devices that fail to parse
+// are left out and reported in the returned error alongside the devices that did parse.
+//
+//goland:noinspection SqlResolve
+func (kdb *KismetDatabase) loadDevices(ctx context.Context) (map[deviceID]*Device, error) {
+	rows, err := kdb.reader.QueryContext(ctx, "SELECT phyname, devmac, device FROM devices")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var (
+		devices   = make(map[deviceID]*Device)
+		parseErrs []error
+	)
+
+	for rows.Next() {
+		var (
+			id   deviceID
+			blob []byte
+		)
+		if err = rows.Scan(&id.phy, &id.mac, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", wrapSQLiteError(err))
+		}
+		d, parseErr := Parse(blob)
+		if parseErr != nil {
+			parseErrs = append(parseErrs, fmt.Errorf("%s: failed to parse %s %s: %w", kdb.path, id.phy, id.mac, parseErr))
+			continue
+		}
+		devices[id] = d
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+
+	return devices, errors.Join(parseErrs...)
+}
+
+// DiffKismetDatabases compares two surveys, such as the same site a month apart. Devices are
+// matched by phy and MAC. Devices that fail to parse are skipped, and reported in the returned
+// error alongside an otherwise complete diff.
+func DiffKismetDatabases(before, after *KismetDatabase) (*DatabaseDiff, error) {
+	return DiffKismetDatabasesCtx(context.Background(), before, after)
+}
+
+// DiffKismetDatabasesCtx is [DiffKismetDatabases] with a context.
+func DiffKismetDatabasesCtx(ctx context.Context, before, after *KismetDatabase) (*DatabaseDiff, error) {
+	beforeDevices, beforeErr := before.loadDevices(ctx)
+	if beforeDevices == nil {
+		return nil, beforeErr
+	}
+	afterDevices, afterErr := after.loadDevices(ctx)
+	if afterDevices == nil {
+		return nil, afterErr
+	}
+
+	dd := &DatabaseDiff{
+		Before:       before.path,
+		After:        after.path,
+		Appeared:     make([]DiffDevice, 0),
+		Disappeared:  make([]DiffDevice, 0),
+		APs:          make([]APChange, 0),
+		Associations: make([]AssociationChange, 0),
+	}
+
+	for _, id := range sortedDeviceIDs(afterDevices) {
+		a := afterDevices[id]
+		b, ok := beforeDevices[id]
+		if !ok {
+			dd.Appeared = append(dd.Appeared, diffDevice(a, id.phy, id.mac))
+			continue
+		}
+		if !a.IsAP() && !b.IsAP() {
+			continue
+		}
+		if changes := diffAccessPoint(b, a); len(changes) > 0 {
+			dd.APs = append(dd.APs, APChange{DiffDevice: diffDevice(a, id.phy, id.mac), Changes: changes})
+		}
+		if joined, left := diffAssociations(b, a); len(joined)+len(left) > 0 {
+			dd.Associations = append(dd.Associations, AssociationChange{
+				DiffDevice: diffDevice(a, id.phy, id.mac), Joined: joined, Left: left,
+			})
+		}
+	}
+
+	for _, id := range sortedDeviceIDs(beforeDevices) {
+		if _, ok := afterDevices[id]; !ok {
+			dd.Disappeared = append(dd.Disappeared, diffDevice(beforeDevices[id], id.phy, id.mac))
+		}
+	}
+
+	return dd, errors.Join(beforeErr, afterErr)
+}
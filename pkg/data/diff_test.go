@@ -0,0 +1,86 @@
+package data
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func insertTestDevice(t *testing.T, db *KismetDatabase, phy, mac, blob string) {
+	t.Helper()
+	//goland:noinspection SqlResolve
+	if _, err := db.conn.Exec(
+		"INSERT INTO devices (devkey, phyname, devmac, device) VALUES (?, ?, ?, ?)", mac+"_key", phy, mac, []byte(blob),
+	); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestDiffKismetDatabases(t *testing.T) {
+	before := newTestDatabase(t, "before.kismet")
+	after := newTestDatabase(t, "after.kismet")
+
+	const wifi = "IEEE802.11"
+
+	insertTestDevice(t, before, wifi, "AA:AA:AA:AA:AA:01", `{
+		"kismet.device.base.type": "Wi-Fi AP", "kismet.device.base.channel": "6", "kismet.device.base.crypt": "WPA2",
+		"dot11.device": {
+			"dot11.device.advertised_ssid_map": [{"dot11.advertisedssid.ssid": "corp", "dot11.advertisedssid.crypt_string": "WPA2-PSK", "dot11.advertisedssid.crypt_bitfield": 2}],
+			"dot11.device.associated_client_map": {"CC:CC:CC:CC:CC:01": "x", "CC:CC:CC:CC:CC:02": "y"}
+		}
+	}`)
+	insertTestDevice(t, after, wifi, "AA:AA:AA:AA:AA:01", `{
+		"kismet.device.base.type": "Wi-Fi AP", "kismet.device.base.channel": "11", "kismet.device.base.crypt": "WPA2",
+		"dot11.device": {
+			"dot11.device.advertised_ssid_map": [
+				{"dot11.advertisedssid.ssid": "corp", "dot11.advertisedssid.crypt_string": "WPA3-SAE", "dot11.advertisedssid.crypt_bitfield": 8},
+				{"dot11.advertisedssid.ssid": "guest", "dot11.advertisedssid.crypt_string": "Open"}
+			],
+			"dot11.device.associated_client_map": {"CC:CC:CC:CC:CC:02": "y", "CC:CC:CC:CC:CC:03": "z"}
+		}
+	}`)
+	insertTestDevice(t, before, wifi, "AA:AA:AA:AA:AA:02", `{"kismet.device.base.type": "Wi-Fi Client"}`)
+	insertTestDevice(t, after, wifi, "AA:AA:AA:AA:AA:03", `{"kismet.device.base.type": "Wi-Fi Client"}`)
+
+	dd, err := DiffKismetDatabases(before, after)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(dd.Appeared) != 1 || dd.Appeared[0].MAC != "AA:AA:AA:AA:AA:03" {
+		t.Errorf("unexpected appeared devices: %v", dd.Appeared)
+	}
+	if len(dd.Disappeared) != 1 || dd.Disappeared[0].MAC != "AA:AA:AA:AA:AA:02" {
+		t.Errorf("unexpected disappeared devices: %v", dd.Disappeared)
+	}
+
+	if len(dd.APs) != 1 {
+		t.Fatalf("expected 1 changed AP, got %v", dd.APs)
+	}
+	fields := make([]string, 0, len(dd.APs[0].Changes))
+	for _, c := range dd.APs[0].Changes {
+		fields = append(fields, c.Field)
+	}
+	if strings.Join(fields, ",") != "channel,ssids,crypt[corp]" {
+		t.Errorf("unexpected AP changes: %v", dd.APs[0].Changes)
+	}
+	if c := dd.APs[0].Changes[2]; c.Before != "WPA2-PSK (0x2)" || c.After != "WPA3-SAE (0x8)" {
+		t.Errorf("unexpected crypt change: %+v", c)
+	}
+
+	if len(dd.Associations) != 1 {
+		t.Fatalf("expected 1 association change, got %v", dd.Associations)
+	}
+	ac := dd.Associations[0]
+	if strings.Join(ac.Joined, ",") != "CC:CC:CC:CC:CC:03" || strings.Join(ac.Left, ",") != "CC:CC:CC:CC:CC:01" {
+		t.Errorf("unexpected association change: %+v", ac)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = dd.WriteText(buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), `channel: "6" -> "11"`) {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+}
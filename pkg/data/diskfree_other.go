@@ -0,0 +1,9 @@
+//go:build !(linux || darwin)
+
+package data
+
+import "errors"
+
+func diskFree(string) (uint64, int64, error) {
+	return 0, 0, errors.ErrUnsupported
+}
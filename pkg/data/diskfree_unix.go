@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package data
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func diskFree(dir string) (fsID uint64, free int64, err error) {
+	var st syscall.Stat_t
+	if err = syscall.Stat(dir, &st); err != nil {
+		return 0, 0, fmt.Errorf("failed to stat '%s': %w", dir, err)
+	}
+	var fs syscall.Statfs_t
+	if err = syscall.Statfs(dir, &fs); err != nil {
+		return 0, 0, fmt.Errorf("failed to statfs '%s': %w", dir, err)
+	}
+	//goland:noinspection GoRedundantConversion
+	return uint64(st.Dev), int64(fs.Bavail) * int64(fs.Bsize), nil
+}
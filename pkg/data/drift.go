@@ -0,0 +1,341 @@
+package data
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// DriftKind classifies how a device blob deviates from the [Device] model.
+type DriftKind int
+
+const (
+	// DriftUnknownKey is a key that the model has no field for.
+	DriftUnknownKey DriftKind = iota
+	// DriftTypeMismatch is a key whose value has a different JSON type than its field.
+	DriftTypeMismatch
+	// DriftMissingKey is a field whose key is absent.
+	DriftMissingKey
+)
+
+var driftKindNames = map[DriftKind]string{
+	DriftUnknownKey:   "unknown",
+	DriftTypeMismatch: "mismatch",
+	DriftMissingKey:   "missing",
+}
+
+func (dk DriftKind) String() string {
+	if name, ok := driftKindNames[dk]; ok {
+		return name
+	}
+	return "DriftKind(" + fmt.Sprint(int(dk)) + ")"
+}
+
+func (dk DriftKind) MarshalText() ([]byte, error) {
+	return []byte(dk.String()), nil
+}
+
+// Drift is one way in which a device blob deviates from the [Device] model. Path is the
+// slash separated keys leading to the value, with "*" standing for any key of a map and
+// "[]" for any element of a list, so that the drifts of different devices can be compared.
+type Drift struct {
+	Kind     DriftKind `json:"kind"`
+	Path     string    `json:"path"`
+	Expected string    `json:"expected,omitempty"`
+	Got      string    `json:"got,omitempty"`
+}
+
+func (d Drift) String() string {
+	if d.Kind == DriftTypeMismatch {
+		return fmt.Sprintf("%s %s: expected %s, got %s", d.Kind, d.Path, d.Expected, d.Got)
+	}
+	return d.Kind.String() + " " + d.Path
+}
+
+// ParseStrict is [Parse] that also reports every drift of b from the model. It still returns
+// the device where Parse would, as the drifts are for reporting rather than rejecting a device.
+func ParseStrict(b []byte) (*Device, []Drift, error) {
+	var raw any
+	if err := sonic.Unmarshal(b, &raw); err != nil {
+		return nil, nil, err
+	}
+	drifts := CheckDevice(raw)
+	d, err := Parse(b)
+	return d, drifts, err
+}
+
+// CheckDevice compares a device blob, decoded into a generic value, against the [Device] model.
+// Only the record of the device's own phy is expected, and checked against its model.
+func CheckDevice(raw any) []Drift {
+	dc := &driftCheck{seen: make(map[Drift]bool), optional: make(map[string]bool)}
+	deviceType := reflect.TypeOf(Device{})
+
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		dc.check("", deviceType, raw)
+		return dc.drifts
+	}
+
+	phy, _ := obj["kismet.device.base.phyname"].(string)
+	obj = maps.Clone(obj)
+	for p, rec := range phyRecords {
+		v, ok := obj[rec.key]
+		switch {
+		case p != phy:
+			dc.optional[rec.key] = true
+		case rec.field == "":
+			// a field of Device, checked with the others
+		case !ok:
+			dc.add(Drift{Kind: DriftMissingKey, Path: rec.key})
+		default:
+			f, _ := deviceType.FieldByName(rec.field)
+			dc.check(rec.key, f.Type, v)
+			delete(obj, rec.key)
+		}
+	}
+
+	dc.check("", deviceType, obj)
+	return dc.drifts
+}
+
+type driftCheck struct {
+	drifts []Drift
+	seen   map[Drift]bool
+	// optional are the paths that aren't reported when missing
+	optional map[string]bool
+}
+
+func (dc *driftCheck) add(d Drift) {
+	// lists and maps of records repeat the same drift for every element
+	if !dc.seen[d] {
+		dc.seen[d] = true
+		dc.drifts = append(dc.drifts, d)
+	}
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonType names the JSON type that values of t are expected to be.
+func jsonType(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "any"
+	}
+}
+
+// valueType names the JSON type of v, telling integers apart from other numbers.
+func valueType(v any) string {
+	switch vv := v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64:
+		if vv == float64(int64(vv)) {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
+
+func (dc *driftCheck) check(path string, t reflect.Type, v any) {
+	if t == rawMessageType || t.Kind() == reflect.Interface || v == nil {
+		return
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	want, got := jsonType(t), valueType(v)
+	switch {
+	case want == got, want == "number" && got == "integer":
+	default:
+		dc.add(Drift{Kind: DriftTypeMismatch, Path: path, Expected: want, Got: got})
+		return
+	}
+
+	switch {
+	case want == "string":
+	case t.Kind() == reflect.Struct:
+		obj := v.(map[string]any)
+		fields := jsonFields(t)
+		known := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			known[f.name] = true
+			fv, ok := obj[f.name]
+			if !ok {
+				if !dc.optional[joinPath(path, f.name)] {
+					dc.add(Drift{Kind: DriftMissingKey, Path: joinPath(path, f.name)})
+				}
+				continue
+			}
+			dc.check(joinPath(path, f.name), t.Field(f.index).Type, fv)
+		}
+		for k := range obj {
+			if !known[k] {
+				dc.add(Drift{Kind: DriftUnknownKey, Path: joinPath(path, k)})
+			}
+		}
+	case t.Kind() == reflect.Map:
+		for _, ev := range v.(map[string]any) {
+			dc.check(joinPath(path, "*"), t.Elem(), ev)
+		}
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		for _, ev := range v.([]any) {
+			dc.check(path+"[]", t.Elem(), ev)
+		}
+	}
+}
+
+// DriftCount is how many devices of a phy share a drift.
+type DriftCount struct {
+	Drift
+	PhyName string `json:"phyname"`
+	Devices int    `json:"devices"`
+}
+
+// DriftReport summarises the drift of a database's device blobs from the [Device] model.
+type DriftReport struct {
+	Path string `json:"path"`
+	// Devices is the number of devices checked, per phy.
+	Devices map[string]int `json:"devices"`
+	// Invalid is the number of device blobs that aren't JSON objects at all.
+	Invalid int `json:"invalid"`
+	// Drifts are ordered by phy, then by the number of devices, most first.
+	Drifts []DriftCount `json:"drifts"`
+}
+
+// SchemaDrift checks every device blob in the database against the [Device] model.
+//
+//goland:noinspection SqlResolve
+func (kdb *KismetDatabase) SchemaDrift(ctx context.Context) (*DriftReport, error) {
+	rows, err := kdb.reader.QueryContext(ctx, "SELECT ifnull(phyname, ''), device FROM devices")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	type phyDrift struct {
+		phy string
+		Drift
+	}
+
+	var (
+		report = &DriftReport{Path: kdb.path, Devices: make(map[string]int)}
+		counts = make(map[phyDrift]int)
+	)
+
+	for rows.Next() {
+		var (
+			phy  string
+			blob []byte
+			raw  any
+		)
+		if err = rows.Scan(&phy, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", wrapSQLiteError(err))
+		}
+		report.Devices[phy]++
+		if sonic.Unmarshal(blob, &raw) != nil {
+			report.Invalid++
+			continue
+		}
+		if _, ok := raw.(map[string]any); !ok {
+			report.Invalid++
+			continue
+		}
+		for _, d := range CheckDevice(raw) {
+			counts[phyDrift{phy: phy, Drift: d}]++
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+
+	for pd, n := range counts {
+		report.Drifts = append(report.Drifts, DriftCount{Drift: pd.Drift, PhyName: pd.phy, Devices: n})
+	}
+	slices.SortFunc(report.Drifts, func(a, b DriftCount) int {
+		if c := strings.Compare(a.PhyName, b.PhyName); c != 0 {
+			return c
+		}
+		if a.Devices != b.Devices {
+			return b.Devices - a.Devices
+		}
+		if a.Kind != b.Kind {
+			return int(a.Kind - b.Kind)
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return report, nil
+}
+
+// WriteText writes the report as a table per phy, leaving out drifts seen in fewer than minDevices devices.
+func (dr *DriftReport) WriteText(w io.Writer, minDevices int) error {
+	var b strings.Builder
+
+	b.WriteString(dr.Path + "\n")
+	if dr.Invalid > 0 {
+		b.WriteString(fmt.Sprintf("  %d devices are not valid JSON objects\n", dr.Invalid))
+	}
+
+	headed := make(map[string]bool)
+	for _, dc := range dr.Drifts {
+		if dc.Devices < minDevices {
+			continue
+		}
+		phy := dc.PhyName
+		if !headed[phy] {
+			headed[phy] = true
+			b.WriteString(fmt.Sprintf("\n%s (%d devices):\n", phy, dr.Devices[phy]))
+		}
+		pct := 100 * float64(dc.Devices) / float64(dr.Devices[phy])
+		b.WriteString(fmt.Sprintf("  %6d %5.1f%%  %s\n", dc.Devices, pct, dc.Drift))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
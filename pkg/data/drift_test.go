@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseStrict(t *testing.T) {
+	d, drifts, err := ParseStrict([]byte(`{
+		"kismet.device.base.macaddr": "AA:AA:AA:AA:AA:01",
+		"kismet.device.base.name": 5,
+		"kismet.device.base.new_in_next_release": true,
+		"dot11.device": {
+			"dot11.device.advertised_ssid_map": {
+				"2": {"dot11.advertisedssid.ssid": "b"},
+				"1": {"dot11.advertisedssid.ssid": "a"}
+			}
+		}
+	}`))
+	if err == nil {
+		t.Error("expected the lenient parse to fail on the mistyped name")
+	}
+	_ = d
+
+	want := []Drift{
+		{Kind: DriftTypeMismatch, Path: "kismet.device.base.name", Expected: "string", Got: "integer"},
+		{Kind: DriftUnknownKey, Path: "kismet.device.base.new_in_next_release"},
+		{Kind: DriftMissingKey, Path: "kismet.device.base.key"},
+		{Kind: DriftTypeMismatch, Path: "dot11.device/dot11.device.advertised_ssid_map", Expected: "array", Got: "object"},
+		{Kind: DriftMissingKey, Path: "dot11.device/dot11.device.last_bssid"},
+	}
+	for _, w := range want {
+		if !slices.Contains(drifts, w) {
+			t.Errorf("missing drift %s", w)
+		}
+	}
+
+	// the map form of the ssid list still parses, in key order
+	d, _, err = ParseStrict([]byte(`{"dot11.device": {"dot11.device.advertised_ssid_map": {
+		"2": {"dot11.advertisedssid.ssid": "b"}, "1": {"dot11.advertisedssid.ssid": "a"}
+	}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssids := d.Dot11.AdvertisedSsidMap; len(ssids) != 2 || ssids[0].SSID != "a" {
+		t.Errorf("unexpected ssids: %+v", ssids)
+	}
+}
+
+func TestSchemaDrift(t *testing.T) {
+	db := newTestDatabase(t, "drift.kismet")
+	insertTestDevice(t, db, "IEEE802.11", "AA:AA:AA:AA:AA:01", testData)
+	insertTestDevice(t, db, "IEEE802.11", "AA:AA:AA:AA:AA:02", testData)
+	insertTestDevice(t, db, "Bluetooth", "AA:AA:AA:AA:AA:03", `{"kismet.device.base.macaddr": "AA:AA:AA:AA:AA:03"}`)
+	insertTestDevice(t, db, "Bluetooth", "AA:AA:AA:AA:AA:04", "[]")
+
+	report, err := db.SchemaDrift(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Devices["IEEE802.11"] != 2 || report.Devices["Bluetooth"] != 2 || report.Invalid != 1 {
+		t.Fatalf("unexpected counts: %+v %d", report.Devices, report.Invalid)
+	}
+
+	var found bool
+	for _, dc := range report.Drifts {
+		if dc.PhyName == "IEEE802.11" && dc.Kind == DriftUnknownKey && dc.Path == "kismet.device.base.packet.bin.250" {
+			found = dc.Devices == 2
+		}
+	}
+	if !found {
+		t.Errorf("expected the unknown key of both wifi devices to be counted: %+v", report.Drifts)
+	}
+
+	var b strings.Builder
+	if err = report.WriteText(&b, 2); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); !strings.Contains(out, "IEEE802.11 (2 devices)") || strings.Contains(out, "Bluetooth (") {
+		t.Errorf("unexpected text report:\n%s", out)
+	}
+}
+
+func TestCheckDevicePhy(t *testing.T) {
+	_, drifts, err := ParseStrict([]byte(testBluetoothData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range drifts {
+		if d.Path == "dot11.device" || d.Path == "bluetooth.device" || d.Kind == DriftTypeMismatch {
+			t.Errorf("unexpected drift %s", d)
+		}
+	}
+	if !slices.Contains(drifts, Drift{Kind: DriftUnknownKey, Path: "bluetooth.device/bluetooth.device.bonded"}) {
+		t.Errorf("expected the bluetooth record to be checked: %v", drifts)
+	}
+}
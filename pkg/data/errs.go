@@ -2,12 +2,52 @@ package data
 
 import (
 	"errors"
-	"strings"
+	"strconv"
 
 	"github.com/glebarez/go-sqlite"
 )
 
-// SQLiteError is an error type that wraps a pointer to an [sqlite.Error].
+// Primary SQLite result codes, see https://www.sqlite.org/rescode.html.
+const (
+	codeBusy       = 5
+	codeLocked     = 6
+	codeReadOnly   = 8
+	codeCorrupt    = 11
+	codeFull       = 13
+	codeCantOpen   = 14
+	codeSchema     = 17
+	codeConstraint = 19
+	codeNotADB     = 26
+
+	// codeNotSQLite marks an error that did not come from SQLite.
+	codeNotSQLite = -1
+)
+
+// Sentinel errors for the classes of SQLite failure callers are expected to handle.
+// Errors returned by this package match them with [errors.Is].
+var (
+	ErrBusy          = errors.New("sqlite: database is busy")
+	ErrLocked        = errors.New("sqlite: table is locked")
+	ErrReadOnly      = errors.New("sqlite: database is read-only")
+	ErrCorrupt       = errors.New("sqlite: database is corrupt")
+	ErrFull          = errors.New("sqlite: database or disk is full")
+	ErrSchemaChanged = errors.New("sqlite: schema changed")
+	ErrConstraint    = errors.New("sqlite: constraint violation")
+	ErrNotADatabase  = errors.New("sqlite: file is not a database")
+)
+
+var codeSentinels = map[int]error{
+	codeBusy:       ErrBusy,
+	codeLocked:     ErrLocked,
+	codeReadOnly:   ErrReadOnly,
+	codeCorrupt:    ErrCorrupt,
+	codeFull:       ErrFull,
+	codeSchema:     ErrSchemaChanged,
+	codeConstraint: ErrConstraint,
+	codeNotADB:     ErrNotADatabase,
+}
+
+// SQLiteError is an error type that wraps an [sqlite.Error] and classifies it by result code.
 type SQLiteError struct {
 	Msg  string
 	code int
@@ -15,32 +55,110 @@ type SQLiteError struct {
 }
 
 func (sqe *SQLiteError) Error() string { return sqe.e.Error() }
-func (sqe *SQLiteError) Code() int     { return sqe.code }
 func (sqe *SQLiteError) Unwrap() error { return sqe.e }
+
+// Code returns the primary result code, or -1 if the error did not come from SQLite.
+func (sqe *SQLiteError) Code() int {
+	if sqe.code < 0 {
+		return sqe.code
+	}
+	return sqe.code & 0xff
+}
+
+// ExtendedCode returns the extended result code, e.g. 517 (SQLITE_BUSY_SNAPSHOT) where
+// Code returns 5 (SQLITE_BUSY). It is the primary code when SQLite gave no extended code.
+func (sqe *SQLiteError) ExtendedCode() int { return sqe.code }
+
+// Is matches the sentinel error for the error's class, e.g. [ErrBusy].
 func (sqe *SQLiteError) Is(target error) bool {
 	if target == nil {
 		return false
 	}
-	return errors.Is(target, sqe.e)
+	sentinel, ok := codeSentinels[sqe.Code()]
+	return ok && target == sentinel
 }
 
-//goland:noinspection GoDirectComparisonOfErrors
-func (sqe *SQLiteError) IsBusy() bool { return sqe.code == 5 }
+func (sqe *SQLiteError) IsBusy() bool          { return sqe.Code() == codeBusy }
+func (sqe *SQLiteError) IsLocked() bool        { return sqe.Code() == codeLocked }
+func (sqe *SQLiteError) IsReadOnly() bool      { return sqe.Code() == codeReadOnly }
+func (sqe *SQLiteError) IsCorrupt() bool       { return sqe.Code() == codeCorrupt }
+func (sqe *SQLiteError) IsFull() bool          { return sqe.Code() == codeFull }
+func (sqe *SQLiteError) IsSchemaChanged() bool { return sqe.Code() == codeSchema }
+func (sqe *SQLiteError) IsConstraint() bool    { return sqe.Code() == codeConstraint }
+func (sqe *SQLiteError) IsNotADatabase() bool  { return sqe.Code() == codeNotADB }
+
+// ErrorAction is what a caller should do about a failed operation.
+type ErrorAction uint8
+
+const (
+	// ActionAbort means the failure will persist, e.g. a full disk or a read-only target.
+	ActionAbort ErrorAction = iota
+	// ActionRetry means the failure is transient, e.g. another writer holding a lock.
+	ActionRetry
+	// ActionSkip means the database involved is unusable but others may be fine,
+	// e.g. a corrupt or truncated source log.
+	ActionSkip
+)
 
+func (a ErrorAction) String() string {
+	switch a {
+	case ActionAbort:
+		return "abort"
+	case ActionRetry:
+		return "retry"
+	case ActionSkip:
+		return "skip"
+	default:
+		return "ErrorAction(" + strconv.Itoa(int(a)) + ")"
+	}
+}
+
+// Action classifies the error, see [ErrorAction].
+func (sqe *SQLiteError) Action() ErrorAction {
+	switch sqe.Code() {
+	case codeBusy, codeLocked, codeSchema:
+		return ActionRetry
+	case codeCorrupt, codeNotADB, codeCantOpen:
+		return ActionSkip
+	default:
+		return ActionAbort
+	}
+}
+
+// ClassifyError returns what to do about err. Errors that did not come from SQLite abort.
+func ClassifyError(err error) ErrorAction {
+	if sqe := NewSQLiteError(err); sqe != nil {
+		return sqe.Action()
+	}
+	return ActionAbort
+}
+
+// NewSQLiteError classifies err, which may wrap an [sqlite.Error] anywhere in its chain.
+// Errors that did not come from SQLite are kept with a code of -1.
 func NewSQLiteError(err error) *SQLiteError {
 	if err == nil {
 		return nil
 	}
-	nerr := new(SQLiteError)
-	nerr.e = err
-	nerr.code = -99
-	var sqe = &sqlite.Error{}
-	if !errors.As(err, &sqe) {
-		nerr.e = sqe
+	var existing *SQLiteError
+	if errors.As(err, &existing) {
+		return existing
+	}
+	nerr := &SQLiteError{Msg: err.Error(), code: codeNotSQLite, e: err}
+	var sqe *sqlite.Error
+	if errors.As(err, &sqe) {
 		nerr.code = sqe.Code()
-	} else if strings.Contains(err.Error(), "(5)") ||
-		strings.Contains(err.Error(), "(SQLITE_BUSY)") {
-		nerr.code = 5
 	}
 	return nerr
 }
+
+// wrapSQLiteError returns err as an [SQLiteError] if it came from SQLite, and unchanged otherwise.
+func wrapSQLiteError(err error) error {
+	var existing *SQLiteError
+	if err == nil || errors.As(err, &existing) {
+		return err
+	}
+	if sqe := NewSQLiteError(err); sqe.code != codeNotSQLite {
+		return sqe
+	}
+	return err
+}
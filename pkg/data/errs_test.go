@@ -0,0 +1,89 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSQLiteError(t *testing.T) {
+	if NewSQLiteError(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+
+	plain := errors.New("not from sqlite")
+	sqe := NewSQLiteError(plain)
+	if sqe.Code() != -1 || sqe.IsBusy() || !errors.Is(sqe, plain) {
+		t.Errorf("unexpected classification of non-sqlite error: %d", sqe.Code())
+	}
+	if ClassifyError(plain) != ActionAbort {
+		t.Error("expected non-sqlite errors to abort")
+	}
+	if wrapSQLiteError(plain) != plain {
+		t.Error("expected non-sqlite errors to be left as is")
+	}
+}
+
+func TestSQLiteErrorClassification(t *testing.T) {
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.kismet")
+	if err := os.WriteFile(garbage, []byte("this is not an sqlite database, not even close, at all"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := OpenKismetDatabase(garbage)
+	if !errors.Is(err, ErrNotADatabase) {
+		t.Fatalf("expected ErrNotADatabase, got %v", err)
+	}
+	if ClassifyError(err) != ActionSkip {
+		t.Errorf("expected not-a-database to be skipped, got %s", ClassifyError(err))
+	}
+
+	db := newTestDatabase(t, "errs.kismet")
+
+	_, err = db.conn.Exec("CREATE TABLE uniq (v INT UNIQUE); INSERT INTO uniq VALUES (1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.conn.Exec("INSERT INTO uniq VALUES (1)")
+	err = fmt.Errorf("insert: %w", wrapSQLiteError(err))
+	var sqe *SQLiteError
+	if !errors.As(err, &sqe) || !sqe.IsConstraint() || !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected constraint violation, got %v", err)
+	}
+	if sqe.ExtendedCode() == sqe.Code() {
+		t.Errorf("expected an extended code for unique constraint, got %d", sqe.ExtendedCode())
+	}
+	if errors.Is(err, ErrBusy) {
+		t.Error("constraint violation must not match ErrBusy")
+	}
+
+	uri, err := readOnlyURI(db.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ro, err := sql.Open("sqlite", uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ro.Close()
+	}()
+	_, err = ro.Exec("INSERT INTO uniq VALUES (2)")
+	if err = wrapSQLiteError(err); !errors.Is(err, ErrReadOnly) || ClassifyError(err) != ActionAbort {
+		t.Fatalf("expected read-only abort, got %v", err)
+	}
+
+	if err = db.SetRetryPolicy(RetryPolicy{MaxAttempts: 1, BusyTimeout: 1}); err != nil {
+		t.Fatal(err)
+	}
+	unlock := lockDatabase(t, db)
+	err = db.Analyze()
+	unlock()
+	if !errors.Is(err, ErrBusy) || !errors.Is(err, ErrRetriesExhausted) || ClassifyError(err) != ActionRetry {
+		t.Fatalf("expected exhausted busy retry, got %v", err)
+	}
+}
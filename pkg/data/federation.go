@@ -0,0 +1,326 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FederatedSourcePlaceholder stands for the schema name of the source a [FederatedQuery] is running against.
+const FederatedSourcePlaceholder = "{src}"
+
+// FederatedQuery is a query run against every source of a [Federation].
+type FederatedQuery struct {
+	// Query is a single SELECT, with every table qualified by [FederatedSourcePlaceholder],
+	// e.g. "SELECT devmac, first_time FROM {src}.devices WHERE phyname = ?".
+	Query string
+	// Args are bound to the query's positional parameters for every source.
+	Args []any
+	// OrderBy is an optional ORDER BY clause, without the keywords, over the query's result columns.
+	OrderBy string
+	// Limit, if positive, caps the number of rows returned across all sources.
+	Limit int
+}
+
+func (fq *FederatedQuery) tail() string {
+	var tail string
+	if fq.OrderBy != "" {
+		tail += " ORDER BY " + fq.OrderBy
+	}
+	if fq.Limit > 0 {
+		tail += " LIMIT " + strconv.Itoa(fq.Limit)
+	}
+	return tail
+}
+
+// Federation runs queries across many Kismet logs without merging them. Sources are attached
+// read-only, a group at a time, to a private in-memory database that holds no data of its own.
+type Federation struct {
+	db        *sql.DB
+	sources   []string
+	groupSize int
+}
+
+// NewFederation creates a [Federation] over sources. A groupSize of 0 attaches as many sources
+// at once as SQLite allows, up to [DefaultGroupSize].
+func NewFederation(groupSize int, sources ...string) (*Federation, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("federation requires at least one source")
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
+	}
+	// every connection to :memory: is its own database, pin ours so attachments and results stick
+	db.SetMaxOpenConns(1)
+
+	// ordered results are collected into a temporary table, keep that out of memory
+	if _, err = db.Exec("PRAGMA temp_store = FILE"); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to set temp store: %w", wrapSQLiteError(err))
+	}
+
+	limit, err := attachLimit(context.Background(), db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	switch {
+	case groupSize == 0:
+		groupSize = min(DefaultGroupSize, limit)
+	case groupSize < 0 || groupSize > limit:
+		_ = db.Close()
+		return nil, fmt.Errorf("group size %d outside of sqlite attach limit of %d", groupSize, limit)
+	}
+
+	return &Federation{db: db, sources: sources, groupSize: groupSize}, nil
+}
+
+// readOnlyURI returns an SQLite URI that opens path read-only.
+func readOnlyURI(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: "mode=ro"}
+	return u.String(), nil
+}
+
+// Query runs fq against every source and returns the combined rows, with the label of the
+// source each row came from as the first column, "source". Without an order or a limit, rows
+// are streamed a group of sources at a time, in the order of the sources; otherwise every
+// group's results are first collected into a temporary table, on disk, and ordering and limits
+// apply across all sources. The federation handles one query at a time, so the rows must be
+// closed before running the next one.
+//
+//goland:noinspection SqlResolve
+func (f *Federation) Query(ctx context.Context, fq *FederatedQuery) (*FederatedRows, error) {
+	if !strings.Contains(fq.Query, FederatedSourcePlaceholder) {
+		return nil, fmt.Errorf("federated query must qualify tables with %s", FederatedSourcePlaceholder)
+	}
+
+	grouped, err := groupSources(f.groupSize, 0, f.sources...)
+	if err != nil {
+		return nil, err
+	}
+
+	fr := &FederatedRows{f: f, ctx: ctx, fq: fq, groups: grouped}
+
+	if fq.OrderBy == "" && fq.Limit <= 0 {
+		if err = fr.nextGroup(); err != nil {
+			return nil, err
+		}
+		return fr, nil
+	}
+
+	if _, err = f.db.ExecContext(ctx, "DROP TABLE IF EXISTS temp.federated"); err != nil {
+		return nil, fmt.Errorf("failed to reset federated results: %w", wrapSQLiteError(err))
+	}
+
+	for i, group := range grouped {
+		if err = f.collectGroup(ctx, fq, group, i == 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if fr.rows, err = f.db.QueryContext(ctx, "SELECT * FROM temp.federated"+fq.tail()); err != nil {
+		return nil, fmt.Errorf("failed to read federated results: %w", wrapSQLiteError(err))
+	}
+	fr.groups = nil
+
+	return fr, nil
+}
+
+// attachGroup attaches a group of sources and returns the query that runs fq against each of
+// them, with its arguments, and a func that detaches them again.
+func (f *Federation) attachGroup(ctx context.Context, fq *FederatedQuery, group []string) (string, []any, func() error, error) {
+	var (
+		selects = make([]string, 0, len(group))
+		args    = make([]any, 0, len(group)*(len(fq.Args)+1))
+		aliases = make([]string, 0, len(group))
+	)
+
+	// detach runs without a context so that it cleans up after a cancelled query
+	detach := func() error {
+		var errs []error
+		for _, alias := range aliases {
+			if _, err := f.db.ExecContext(context.Background(), detachQuery(alias)); err != nil {
+				errs = append(errs, fmt.Errorf("failed to detach %s: %w", alias, wrapSQLiteError(err)))
+			}
+		}
+		aliases = aliases[:0]
+		return errors.Join(errs...)
+	}
+
+	for i, source := range group {
+		uri, err := readOnlyURI(source)
+		if err != nil {
+			return "", nil, nil, errors.Join(err, detach())
+		}
+		alias := "fed" + strconv.Itoa(i)
+		if _, err = f.db.ExecContext(ctx, attachQuery(uri, alias)); err != nil {
+			return "", nil, nil, errors.Join(fmt.Errorf("failed to attach %s: %w", source, wrapSQLiteError(err)), detach())
+		}
+		aliases = append(aliases, alias)
+
+		selects = append(selects, "SELECT ? AS source, q.* FROM ("+strings.ReplaceAll(fq.Query, FederatedSourcePlaceholder, alias)+") q")
+		args = append(append(args, source), fq.Args...)
+	}
+
+	return strings.Join(selects, " UNION ALL "), args, detach, nil
+}
+
+// collectGroup attaches a group of sources, runs fq against each, and stores the group's
+// results; only the group's top rows are kept when fq has a limit.
+//
+//goland:noinspection SqlResolve
+func (f *Federation) collectGroup(ctx context.Context, fq *FederatedQuery, group []string, first bool) (err error) {
+	query, args, detach, err := f.attachGroup(ctx, fq, group)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, detach())
+	}()
+
+	query = "SELECT * FROM (" + query + ")" + fq.tail()
+	if first {
+		query = "CREATE TEMP TABLE federated AS " + query
+	} else {
+		query = "INSERT INTO temp.federated " + query
+	}
+
+	if _, err = f.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("federated query failed: %w", wrapSQLiteError(err))
+	}
+
+	return nil
+}
+
+// FederatedRows are the results of a [Federation.Query], read like [sql.Rows].
+type FederatedRows struct {
+	f   *Federation
+	ctx context.Context
+	fq  *FederatedQuery
+	// groups are the groups of sources still to be queried when streaming.
+	groups [][]string
+
+	rows    *sql.Rows
+	columns []string
+	// detach detaches the group of sources that rows are read from, when streaming.
+	detach func() error
+	err    error
+	closed bool
+}
+
+// nextGroup attaches the next group of sources and starts reading its rows.
+func (fr *FederatedRows) nextGroup() error {
+	group := fr.groups[0]
+	fr.groups = fr.groups[1:]
+
+	query, args, detach, err := fr.f.attachGroup(fr.ctx, fr.fq, group)
+	if err != nil {
+		return err
+	}
+	rows, err := fr.f.db.QueryContext(fr.ctx, query, args...)
+	if err != nil {
+		return errors.Join(fmt.Errorf("federated query failed: %w", wrapSQLiteError(err)), detach())
+	}
+	if fr.columns == nil {
+		if fr.columns, err = rows.Columns(); err != nil {
+			_ = rows.Close()
+			return errors.Join(fmt.Errorf("failed to read columns: %w", wrapSQLiteError(err)), detach())
+		}
+	}
+	fr.rows, fr.detach = rows, detach
+	return nil
+}
+
+// endGroup stops reading the current rows and detaches their sources, if streaming.
+func (fr *FederatedRows) endGroup() error {
+	var errs []error
+	if fr.rows != nil {
+		if err := fr.rows.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to read federated results: %w", wrapSQLiteError(err)))
+		}
+		if err := fr.rows.Close(); err != nil {
+			errs = append(errs, wrapSQLiteError(err))
+		}
+		fr.rows = nil
+	}
+	if fr.detach != nil {
+		errs = append(errs, fr.detach())
+		fr.detach = nil
+	}
+	return errors.Join(errs...)
+}
+
+// Next prepares the next row for [FederatedRows.Scan], moving on to the next group of sources
+// once a group is exhausted. It reports false at the end of the results or on error, see
+// [FederatedRows.Err].
+func (fr *FederatedRows) Next() bool {
+	for fr.err == nil && !fr.closed && fr.rows != nil {
+		if fr.rows.Next() {
+			return true
+		}
+		if fr.err = fr.endGroup(); fr.err == nil && len(fr.groups) > 0 {
+			fr.err = fr.nextGroup()
+		}
+	}
+	return false
+}
+
+// Scan copies the columns of the current row into dest, see [sql.Rows.Scan].
+func (fr *FederatedRows) Scan(dest ...any) error {
+	if fr.rows == nil {
+		return errors.New("federated rows are closed")
+	}
+	return fr.rows.Scan(dest...)
+}
+
+// Columns returns the names of the result columns, the first being "source".
+func (fr *FederatedRows) Columns() ([]string, error) {
+	if fr.columns != nil {
+		return fr.columns, nil
+	}
+	if fr.rows == nil {
+		return nil, errors.New("federated rows are closed")
+	}
+	return fr.rows.Columns()
+}
+
+// Err returns the error, if any, that ended [FederatedRows.Next].
+func (fr *FederatedRows) Err() error {
+	if fr.err != nil {
+		return fr.err
+	}
+	if fr.rows != nil {
+		return wrapSQLiteError(fr.rows.Err())
+	}
+	return nil
+}
+
+// Close stops reading the results and detaches any sources still attached. It is safe to
+// call more than once.
+func (fr *FederatedRows) Close() error {
+	if fr.closed {
+		return nil
+	}
+	fr.closed = true
+	fr.groups = nil
+	return fr.endGroup()
+}
+
+func (f *Federation) Close() error {
+	if err := f.db.Close(); err != nil {
+		return fmt.Errorf("failed to close federation: %w", wrapSQLiteError(err))
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestFederation(t *testing.T) {
+	sources := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		src := newTestDatabase(t, "source"+strconv.Itoa(i)+".kismet")
+		seedTestDatabase(t, src, "00:11:22:33:44:0"+strconv.Itoa(i), 3+i)
+		sources = append(sources, src.String())
+	}
+
+	fed, err := NewFederation(2, sources...)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		_ = fed.Close()
+	}()
+
+	if _, err = fed.Query(context.Background(), &FederatedQuery{Query: "SELECT * FROM packets"}); err == nil {
+		t.Error("expected error for query without source placeholder")
+	}
+
+	rows, err := fed.Query(context.Background(), &FederatedQuery{
+		Query:   "SELECT sourcemac, ts_sec FROM {src}.packets WHERE ts_sec >= ?",
+		Args:    []any{2},
+		OrderBy: "ts_sec DESC, sourcemac",
+		Limit:   4,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	type row struct {
+		source, mac string
+		ts          int
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.source, &r.mac, &r.ts); err != nil {
+			t.Fatal(err.Error())
+		}
+		got = append(got, r)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := []row{
+		{sources[2], "00:11:22:33:44:02", 4},
+		{sources[1], "00:11:22:33:44:01", 3},
+		{sources[2], "00:11:22:33:44:02", 3},
+		{sources[0], "00:11:22:33:44:00", 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rows, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	// the federation must be reusable once the previous rows are closed
+	var n int
+	if err = fed.db.QueryRow("SELECT count(*) FROM pragma_database_list").Scan(&n); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 2 {
+		t.Errorf("expected sources to be detached, %d databases still listed", n)
+	}
+}
+
+func TestFederationStreaming(t *testing.T) {
+	sources := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		src := newTestDatabase(t, "source"+strconv.Itoa(i)+".kismet")
+		seedTestDatabase(t, src, "00:11:22:33:44:0"+strconv.Itoa(i), 3+i)
+		sources = append(sources, src.String())
+	}
+
+	fed, err := NewFederation(2, sources...)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		_ = fed.Close()
+	}()
+
+	query := &FederatedQuery{Query: "SELECT sourcemac FROM {src}.packets"}
+
+	// stopping early must detach the group being read
+	rows, err := fed.Query(context.Background(), query)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !rows.Next() {
+		t.Fatalf("expected a row: %v", rows.Err())
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rows, err = fed.Query(context.Background(), query)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cols, colErr := rows.Columns(); colErr != nil || len(cols) != 2 || cols[0] != "source" {
+		t.Errorf("unexpected columns %v: %v", cols, colErr)
+	}
+	counts := make(map[string]int)
+	var order []string
+	for rows.Next() {
+		var source, mac string
+		if err = rows.Scan(&source, &mac); err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(order) == 0 || order[len(order)-1] != source {
+			order = append(order, source)
+		}
+		counts[source]++
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(order) != 3 || order[0] != sources[0] || order[1] != sources[1] || order[2] != sources[2] {
+		t.Errorf("expected rows in the order of the sources, got %v", order)
+	}
+	for i, source := range sources {
+		if counts[source] != 3+i {
+			t.Errorf("%s: expected %d rows, got %d", source, 3+i, counts[source])
+		}
+	}
+
+	var n int
+	if err = fed.db.QueryRow("SELECT count(*) FROM pragma_database_list").Scan(&n); err != nil {
+		t.Fatal(err.Error())
+	}
+	// only main, as nothing was written to temp
+	if n != 1 {
+		t.Errorf("expected sources to be detached, %d databases still listed", n)
+	}
+	//goland:noinspection SqlResolve
+	if err = fed.db.QueryRow("SELECT count(*) FROM temp.sqlite_master WHERE name = 'federated'").Scan(&n); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 0 {
+		t.Error("expected streamed results not to be collected into a temporary table")
+	}
+	if err = fed.db.QueryRow("PRAGMA temp_store").Scan(&n); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 1 {
+		t.Errorf("expected a file backed temp store, got %d", n)
+	}
+}
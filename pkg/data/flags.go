@@ -0,0 +1,496 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The bitfields Kismet logs, as defined by its headers. They decode from, and encode to,
+// the integers Kismet logs.
+
+// flagName names one bit of a bitfield.
+type flagName struct {
+	bit  uint64
+	name string
+}
+
+// flagString names the bits set in v, joined by "|", with any bits not in names in hex.
+func flagString(v uint64, names []flagName) string {
+	if v == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, fn := range names {
+		if v&fn.bit != 0 {
+			parts = append(parts, fn.name)
+			v &^= fn.bit
+		}
+	}
+	if v != 0 {
+		parts = append(parts, "0x"+strconv.FormatUint(v, 16))
+	}
+	return strings.Join(parts, "|")
+}
+
+// BasicTypeSet is the phy-independent kind of a device, kismet.device.base.basic_type_set.
+type BasicTypeSet uint64
+
+const (
+	BasicTypeAP BasicTypeSet = 1 << iota
+	BasicTypeClient
+	BasicTypeWired
+	BasicTypePeer
+)
+
+var basicTypeNames = []flagName{
+	{uint64(BasicTypeAP), "AP"},
+	{uint64(BasicTypeClient), "Client"},
+	{uint64(BasicTypeWired), "Wired"},
+	{uint64(BasicTypePeer), "Peer"},
+}
+
+func (ts BasicTypeSet) String() string {
+	return flagString(uint64(ts), basicTypeNames)
+}
+
+// IsAP reports whether the device is an access point.
+func (ts BasicTypeSet) IsAP() bool {
+	return ts&BasicTypeAP != 0
+}
+
+// IsClient reports whether the device is a client of an access point.
+func (ts BasicTypeSet) IsClient() bool {
+	return ts&BasicTypeClient != 0
+}
+
+// IsWired reports whether the device was seen on the wired side of a network.
+func (ts BasicTypeSet) IsWired() bool {
+	return ts&BasicTypeWired != 0
+}
+
+// IsPeer reports whether the device is a peer in an ad-hoc or mesh network.
+func (ts BasicTypeSet) IsPeer() bool {
+	return ts&BasicTypePeer != 0
+}
+
+// BasicCryptSet is the phy-independent encryption of a device, kismet.device.base.basic_crypt_set.
+type BasicCryptSet uint64
+
+const (
+	BasicCryptEncrypted BasicCryptSet = 1 << (iota + 1)
+	BasicCryptL2
+	BasicCryptL3
+	BasicCryptWeak
+	BasicCryptDecrypted
+)
+
+var basicCryptNames = []flagName{
+	{uint64(BasicCryptEncrypted), "Encrypted"},
+	{uint64(BasicCryptL2), "L2"},
+	{uint64(BasicCryptL3), "L3"},
+	{uint64(BasicCryptWeak), "Weak"},
+	{uint64(BasicCryptDecrypted), "Decrypted"},
+}
+
+func (cs BasicCryptSet) String() string {
+	return flagString(uint64(cs), basicCryptNames)
+}
+
+// IsOpen reports whether the device uses no encryption that Kismet could tell.
+func (cs BasicCryptSet) IsOpen() bool {
+	return cs == 0
+}
+
+// IsEncrypted reports whether the device uses encryption, at the link layer or above.
+func (cs BasicCryptSet) IsEncrypted() bool {
+	return cs&(BasicCryptEncrypted|BasicCryptL2|BasicCryptL3) != 0
+}
+
+// IsWeak reports whether the device uses encryption known to be broken, such as WEP.
+func (cs BasicCryptSet) IsWeak() bool {
+	return cs&BasicCryptWeak != 0
+}
+
+// IsDecrypted reports whether Kismet decrypted the device's traffic.
+func (cs BasicCryptSet) IsDecrypted() bool {
+	return cs&BasicCryptDecrypted != 0
+}
+
+// Dot11TypeSet is the kind of an 802.11 device, dot11.device.typeset.
+type Dot11TypeSet uint64
+
+const (
+	Dot11TypeBeaconAP Dot11TypeSet = 1 << iota
+	Dot11TypeAdhoc
+	Dot11TypeClient
+	Dot11TypeWired
+	Dot11TypeWDS
+	Dot11TypeTurbocell
+	Dot11TypeInferredWireless
+	Dot11TypeInferredWired
+	Dot11TypeProbeAP
+)
+
+var dot11TypeNames = []flagName{
+	{uint64(Dot11TypeBeaconAP), "BeaconAP"},
+	{uint64(Dot11TypeAdhoc), "Adhoc"},
+	{uint64(Dot11TypeClient), "Client"},
+	{uint64(Dot11TypeWired), "Wired"},
+	{uint64(Dot11TypeWDS), "WDS"},
+	{uint64(Dot11TypeTurbocell), "Turbocell"},
+	{uint64(Dot11TypeInferredWireless), "InferredWireless"},
+	{uint64(Dot11TypeInferredWired), "InferredWired"},
+	{uint64(Dot11TypeProbeAP), "ProbeAP"},
+}
+
+func (ts Dot11TypeSet) String() string {
+	return flagString(uint64(ts), dot11TypeNames)
+}
+
+// IsAP reports whether the device beaconed or answered probes as an access point.
+func (ts Dot11TypeSet) IsAP() bool {
+	return ts&(Dot11TypeBeaconAP|Dot11TypeProbeAP) != 0
+}
+
+// IsClient reports whether the device was seen as a client of an access point.
+func (ts Dot11TypeSet) IsClient() bool {
+	return ts&Dot11TypeClient != 0
+}
+
+// IsAdhoc reports whether the device was seen in an ad-hoc network.
+func (ts Dot11TypeSet) IsAdhoc() bool {
+	return ts&Dot11TypeAdhoc != 0
+}
+
+// IsWDS reports whether the device was seen in a wireless distribution system.
+func (ts Dot11TypeSet) IsWDS() bool {
+	return ts&Dot11TypeWDS != 0
+}
+
+// IsWired reports whether the device was seen, or inferred to be, on the wired side of a network.
+func (ts Dot11TypeSet) IsWired() bool {
+	return ts&(Dot11TypeWired|Dot11TypeInferredWired) != 0
+}
+
+// Dot11CryptSet is the encryption an 802.11 network advertises, as in the crypt set
+// of dot11.device.advertised_ssid_map.
+type Dot11CryptSet uint64
+
+const (
+	Dot11CryptUnknown Dot11CryptSet = 1 << iota
+	Dot11CryptWEP
+	Dot11CryptLayer3
+	Dot11CryptWEP40
+	Dot11CryptWEP104
+	Dot11CryptTKIP
+	Dot11CryptWPA
+	Dot11CryptPSK
+	Dot11CryptAESOCB
+	Dot11CryptAESCCM
+	Dot11CryptWPAMigration
+	Dot11CryptEAP
+	Dot11CryptLEAP
+	Dot11CryptTTLS
+	Dot11CryptTLS
+	Dot11CryptPEAP
+	Dot11CryptSAE
+	Dot11CryptOWE
+	_
+	_
+	Dot11CryptISAKMP
+	Dot11CryptPPTP
+	Dot11CryptFortress
+	Dot11CryptKeyguard
+	Dot11CryptUnknownProtected
+	Dot11CryptUnknownNonWEP
+	Dot11CryptWPS
+	Dot11CryptVersionWPA
+	Dot11CryptVersionWPA2
+	Dot11CryptVersionWPA3
+)
+
+const (
+	// dot11CryptProtectMask covers the link layer protection bits.
+	dot11CryptProtectMask Dot11CryptSet = 0xfffff
+	dot11CryptL3Mask                    = Dot11CryptLayer3 | Dot11CryptISAKMP | Dot11CryptPPTP
+	dot11CryptWPAMask                   = Dot11CryptWPA | Dot11CryptVersionWPA | Dot11CryptVersionWPA2 |
+		Dot11CryptVersionWPA3 | Dot11CryptSAE | Dot11CryptOWE
+	dot11CryptEnterpriseMask = Dot11CryptEAP | Dot11CryptLEAP | Dot11CryptTTLS | Dot11CryptTLS | Dot11CryptPEAP
+)
+
+var dot11CryptNames = []flagName{
+	{uint64(Dot11CryptUnknown), "Unknown"},
+	{uint64(Dot11CryptWEP), "WEP"},
+	{uint64(Dot11CryptLayer3), "Layer3"},
+	{uint64(Dot11CryptWEP40), "WEP40"},
+	{uint64(Dot11CryptWEP104), "WEP104"},
+	{uint64(Dot11CryptTKIP), "TKIP"},
+	{uint64(Dot11CryptWPA), "WPA"},
+	{uint64(Dot11CryptPSK), "PSK"},
+	{uint64(Dot11CryptAESOCB), "AES-OCB"},
+	{uint64(Dot11CryptAESCCM), "AES-CCM"},
+	{uint64(Dot11CryptWPAMigration), "WPA-Migration"},
+	{uint64(Dot11CryptEAP), "EAP"},
+	{uint64(Dot11CryptLEAP), "LEAP"},
+	{uint64(Dot11CryptTTLS), "TTLS"},
+	{uint64(Dot11CryptTLS), "TLS"},
+	{uint64(Dot11CryptPEAP), "PEAP"},
+	{uint64(Dot11CryptSAE), "SAE"},
+	{uint64(Dot11CryptOWE), "OWE"},
+	{uint64(Dot11CryptISAKMP), "ISAKMP"},
+	{uint64(Dot11CryptPPTP), "PPTP"},
+	{uint64(Dot11CryptFortress), "Fortress"},
+	{uint64(Dot11CryptKeyguard), "Keyguard"},
+	{uint64(Dot11CryptUnknownProtected), "UnknownProtected"},
+	{uint64(Dot11CryptUnknownNonWEP), "UnknownNonWEP"},
+	{uint64(Dot11CryptWPS), "WPS"},
+	{uint64(Dot11CryptVersionWPA), "WPA1"},
+	{uint64(Dot11CryptVersionWPA2), "WPA2"},
+	{uint64(Dot11CryptVersionWPA3), "WPA3"},
+}
+
+func (cs Dot11CryptSet) String() string {
+	return flagString(uint64(cs), dot11CryptNames)
+}
+
+// IsOpen reports whether the network advertises no encryption, at the link layer or above.
+func (cs Dot11CryptSet) IsOpen() bool {
+	return cs&(dot11CryptProtectMask|dot11CryptL3Mask) == 0
+}
+
+// IsWEP reports whether the network uses WEP rather than WPA, including WEP of unknown key length.
+func (cs Dot11CryptSet) IsWEP() bool {
+	return cs&(Dot11CryptWEP|Dot11CryptWEP40|Dot11CryptWEP104) != 0 && cs&dot11CryptWPAMask == 0
+}
+
+// IsWPA reports whether the network uses any version of WPA.
+func (cs Dot11CryptSet) IsWPA() bool {
+	return cs&dot11CryptWPAMask != 0
+}
+
+// IsWPA2 reports whether the network offers WPA2, the RSN with AES-CCM.
+func (cs Dot11CryptSet) IsWPA2() bool {
+	return cs&Dot11CryptVersionWPA2 != 0 || (cs&Dot11CryptWPA != 0 && cs&Dot11CryptAESCCM != 0)
+}
+
+// IsWPA3 reports whether the network offers WPA3.
+func (cs Dot11CryptSet) IsWPA3() bool {
+	return cs&(Dot11CryptVersionWPA3|Dot11CryptSAE) != 0
+}
+
+// IsWPA3SAE reports whether the network offers SAE, the WPA3 replacement for a pre-shared key.
+func (cs Dot11CryptSet) IsWPA3SAE() bool {
+	return cs&Dot11CryptSAE != 0
+}
+
+// IsOWE reports whether the network uses opportunistic wireless encryption, encrypted "open" Wi-Fi.
+func (cs Dot11CryptSet) IsOWE() bool {
+	return cs&Dot11CryptOWE != 0
+}
+
+// IsPSK reports whether the network uses a WPA pre-shared key.
+func (cs Dot11CryptSet) IsPSK() bool {
+	return cs&Dot11CryptPSK != 0
+}
+
+// IsEnterprise reports whether the network authenticates through 802.1X.
+func (cs Dot11CryptSet) IsEnterprise() bool {
+	return cs&dot11CryptEnterpriseMask != 0
+}
+
+// IsWPS reports whether the network advertises Wi-Fi Protected Setup.
+func (cs Dot11CryptSet) IsWPS() bool {
+	return cs&Dot11CryptWPS != 0
+}
+
+// Dot11Encryption is a kind of 802.11 encryption, one for each predicate of [Dot11CryptSet],
+// that [DeviceQuery] selects networks by.
+type Dot11Encryption uint8
+
+const (
+	Dot11EncryptionAny Dot11Encryption = iota
+	Dot11EncryptionOpen
+	Dot11EncryptionWEP
+	Dot11EncryptionWPA
+	Dot11EncryptionWPA2
+	Dot11EncryptionWPA3
+	Dot11EncryptionWPA3SAE
+	Dot11EncryptionOWE
+	Dot11EncryptionPSK
+	Dot11EncryptionEnterprise
+	Dot11EncryptionWPS
+)
+
+var dot11EncryptionNames = map[Dot11Encryption]string{
+	Dot11EncryptionAny:        "any",
+	Dot11EncryptionOpen:       "open",
+	Dot11EncryptionWEP:        "wep",
+	Dot11EncryptionWPA:        "wpa",
+	Dot11EncryptionWPA2:       "wpa2",
+	Dot11EncryptionWPA3:       "wpa3",
+	Dot11EncryptionWPA3SAE:    "sae",
+	Dot11EncryptionOWE:        "owe",
+	Dot11EncryptionPSK:        "psk",
+	Dot11EncryptionEnterprise: "enterprise",
+	Dot11EncryptionWPS:        "wps",
+}
+
+func (de Dot11Encryption) String() string {
+	if name, ok := dot11EncryptionNames[de]; ok {
+		return name
+	}
+	return fmt.Sprintf("Dot11Encryption(%d)", de)
+}
+
+// ParseDot11Encryption returns the [Dot11Encryption] named by s, see [Dot11Encryption.String].
+func ParseDot11Encryption(s string) (Dot11Encryption, error) {
+	for de, name := range dot11EncryptionNames {
+		if strings.EqualFold(s, name) {
+			return de, nil
+		}
+	}
+	return Dot11EncryptionAny, fmt.Errorf("unknown 802.11 encryption: %s", s)
+}
+
+// Matches reports whether cs is of the kind de, as told by the predicate of the same name.
+func (de Dot11Encryption) Matches(cs Dot11CryptSet) bool {
+	switch de {
+	case Dot11EncryptionAny:
+		return true
+	case Dot11EncryptionOpen:
+		return cs.IsOpen()
+	case Dot11EncryptionWEP:
+		return cs.IsWEP()
+	case Dot11EncryptionWPA:
+		return cs.IsWPA()
+	case Dot11EncryptionWPA2:
+		return cs.IsWPA2()
+	case Dot11EncryptionWPA3:
+		return cs.IsWPA3()
+	case Dot11EncryptionWPA3SAE:
+		return cs.IsWPA3SAE()
+	case Dot11EncryptionOWE:
+		return cs.IsOWE()
+	case Dot11EncryptionPSK:
+		return cs.IsPSK()
+	case Dot11EncryptionEnterprise:
+		return cs.IsEnterprise()
+	case Dot11EncryptionWPS:
+		return cs.IsWPS()
+	default:
+		return false
+	}
+}
+
+// sql returns the SQL condition on the crypt set cs that [Dot11Encryption.Matches] the same
+// crypt sets, or false for an unknown de.
+func (de Dot11Encryption) sql(cs string) (string, bool) {
+	has := func(mask Dot11CryptSet) string {
+		return fmt.Sprintf("(%s & %d) != 0", cs, mask)
+	}
+	switch de {
+	case Dot11EncryptionAny:
+		return "1", true
+	case Dot11EncryptionOpen:
+		return fmt.Sprintf("(%s & %d) = 0", cs, dot11CryptProtectMask|dot11CryptL3Mask), true
+	case Dot11EncryptionWEP:
+		return has(Dot11CryptWEP|Dot11CryptWEP40|Dot11CryptWEP104) + " AND NOT " + has(dot11CryptWPAMask), true
+	case Dot11EncryptionWPA:
+		return has(dot11CryptWPAMask), true
+	case Dot11EncryptionWPA2:
+		return "(" + has(Dot11CryptVersionWPA2) + " OR (" + has(Dot11CryptWPA) + " AND " + has(Dot11CryptAESCCM) + "))", true
+	case Dot11EncryptionWPA3:
+		return has(Dot11CryptVersionWPA3 | Dot11CryptSAE), true
+	case Dot11EncryptionWPA3SAE:
+		return has(Dot11CryptSAE), true
+	case Dot11EncryptionOWE:
+		return has(Dot11CryptOWE), true
+	case Dot11EncryptionPSK:
+		return has(Dot11CryptPSK), true
+	case Dot11EncryptionEnterprise:
+		return has(dot11CryptEnterpriseMask), true
+	case Dot11EncryptionWPS:
+		return has(Dot11CryptWPS), true
+	default:
+		return "", false
+	}
+}
+
+// CarrierSet is the carriers a device was seen on, kismet.common.signal.carrierset.
+type CarrierSet uint64
+
+const (
+	CarrierDot11B CarrierSet = 1 << iota
+	CarrierDot11BPlus
+	CarrierDot11A
+	CarrierDot11G
+	CarrierDot11FHSS
+	CarrierDot11DSSS
+	CarrierDot11N20
+	CarrierDot11N40
+)
+
+var carrierNames = []flagName{
+	{uint64(CarrierDot11B), "802.11b"},
+	{uint64(CarrierDot11BPlus), "802.11b+"},
+	{uint64(CarrierDot11A), "802.11a"},
+	{uint64(CarrierDot11G), "802.11g"},
+	{uint64(CarrierDot11FHSS), "802.11FHSS"},
+	{uint64(CarrierDot11DSSS), "802.11DSSS"},
+	{uint64(CarrierDot11N20), "802.11n20"},
+	{uint64(CarrierDot11N40), "802.11n40"},
+}
+
+func (cs CarrierSet) String() string {
+	return flagString(uint64(cs), carrierNames)
+}
+
+// Has reports whether every carrier of c is set.
+func (cs CarrierSet) Has(c CarrierSet) bool {
+	return cs&c == c
+}
+
+// EncodingSet is the encodings a device was seen using, kismet.common.signal.encodingset.
+type EncodingSet uint64
+
+const (
+	EncodingCCK EncodingSet = 1 << iota
+	EncodingPBCC
+	EncodingOFDM
+	EncodingDynamicCCK
+	EncodingGFSK
+)
+
+var encodingNames = []flagName{
+	{uint64(EncodingCCK), "CCK"},
+	{uint64(EncodingPBCC), "PBCC"},
+	{uint64(EncodingOFDM), "OFDM"},
+	{uint64(EncodingDynamicCCK), "DynamicCCK"},
+	{uint64(EncodingGFSK), "GFSK"},
+}
+
+func (es EncodingSet) String() string {
+	return flagString(uint64(es), encodingNames)
+}
+
+// Has reports whether every encoding of e is set.
+func (es EncodingSet) Has(e EncodingSet) bool {
+	return es&e == e
+}
+
+// IsAP reports whether the device is an access point, by any of its kind, its 802.11 kind and
+// the SSIDs it advertised.
+func (d *Device) IsAP() bool {
+	return d.BaseBasicTypeSet.IsAP() || d.Dot11.Typeset.IsAP() || len(d.Dot11.AdvertisedSsidMap) > 0
+}
+
+// IsClient reports whether the device is a client, by its kind or its 802.11 kind.
+func (d *Device) IsClient() bool {
+	return d.BaseBasicTypeSet.IsClient() || d.Dot11.Typeset.IsClient()
+}
+
+// IsWDS reports whether the device was seen in a wireless distribution system.
+func (d *Device) IsWDS() bool {
+	return d.Dot11.Typeset.IsWDS()
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFlagSets(t *testing.T) {
+	sae := Dot11CryptWPA | Dot11CryptSAE | Dot11CryptAESCCM | Dot11CryptVersionWPA3
+	if s := sae.String(); s != "WPA|AES-CCM|SAE|WPA3" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if !sae.IsWPA3SAE() || !sae.IsWPA3() || !sae.IsWPA2() || sae.IsOpen() || sae.IsWEP() || sae.IsOWE() {
+		t.Errorf("unexpected predicates for %s", sae)
+	}
+
+	for _, tc := range []struct {
+		crypt Dot11CryptSet
+		open  bool
+		wep   bool
+		owe   bool
+	}{
+		{0, true, false, false},
+		{Dot11CryptWPS, true, false, false},
+		{Dot11CryptWEP | Dot11CryptWEP104, false, true, false},
+		{Dot11CryptWEP | Dot11CryptWPA | Dot11CryptTKIP | Dot11CryptWPAMigration, false, false, false},
+		{Dot11CryptOWE | Dot11CryptAESCCM, false, false, true},
+		{Dot11CryptPPTP, false, false, false},
+	} {
+		if tc.crypt.IsOpen() != tc.open || tc.crypt.IsWEP() != tc.wep || tc.crypt.IsOWE() != tc.owe {
+			t.Errorf("unexpected predicates for %s", tc.crypt)
+		}
+	}
+
+	if s := (BasicTypeAP | 1<<10).String(); s != "AP|0x400" {
+		t.Errorf("expected unknown bits in hex, got %q", s)
+	}
+	if s := BasicCryptSet(0).String(); s != "none" {
+		t.Errorf("unexpected string %q", s)
+	}
+
+	d, err := Parse([]byte(`{"kismet.device.base.basic_type_set": 2, "dot11.device": {"dot11.device.typeset": 20},
+		"kismet.device.base.signal": {"kismet.common.signal.carrierset": 72, "kismet.common.signal.encodingset": 4}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IsAP() || !d.IsClient() || !d.IsWDS() || d.Dot11.Typeset.String() != "Client|WDS" {
+		t.Errorf("unexpected kind %s / %s", d.BaseBasicTypeSet, d.Dot11.Typeset)
+	}
+	signal := d.BaseSignal
+	if !signal.CommonSignalCarrierset.Has(CarrierDot11G|CarrierDot11N20) || !signal.CommonSignalEncodingset.Has(EncodingOFDM) {
+		t.Errorf("unexpected carriers %s and encodings %s", signal.CommonSignalCarrierset, signal.CommonSignalEncodingset)
+	}
+}
+
+func TestParseDot11Encryption(t *testing.T) {
+	for de, name := range dot11EncryptionNames {
+		got, err := ParseDot11Encryption(strings.ToUpper(name))
+		if err != nil || got != de {
+			t.Errorf("%s: expected %d, got %d (%v)", name, de, got, err)
+		}
+	}
+	if _, err := ParseDot11Encryption("wpa4"); err == nil {
+		t.Error("expected unknown encryption to be rejected")
+	}
+}
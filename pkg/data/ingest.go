@@ -22,5 +22,11 @@ func Parse(b []byte) (*Device, error) {
 	if err := sonic.Unmarshal(b, d); err != nil {
 		return nil, err
 	}
+	d.Enrich(nil)
 	return d, nil
 }
+
+// Encode is the reverse of [Parse], returning the device blob as Kismet logs it.
+func Encode(d *Device) ([]byte, error) {
+	return d.MarshalJSON()
+}
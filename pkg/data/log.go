@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// discardHandler drops every record, it is what the package logs to until [SetLogger] is called.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var pkgLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	pkgLogger.Store(slog.New(discardHandler{}))
+}
+
+// SetLogger sets the logger used by the package, and by every [KismetDatabase] without
+// a logger of its own. A nil logger discards everything, which is the default.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(discardHandler{})
+	}
+	pkgLogger.Store(l)
+}
+
+// Logger returns the logger set with [SetLogger].
+func Logger() *slog.Logger {
+	return pkgLogger.Load()
+}
+
+// SetLogger sets the logger used for operations on this database, overriding the package logger.
+// A nil logger reverts to the package logger.
+func (kdb *KismetDatabase) SetLogger(l *slog.Logger) {
+	kdb.mu.Lock()
+	kdb.logger = l
+	kdb.mu.Unlock()
+}
+
+// Logger returns the database's logger, with the database path attached as "db".
+func (kdb *KismetDatabase) Logger() *slog.Logger {
+	kdb.mu.Lock()
+	l := kdb.logger
+	kdb.mu.Unlock()
+	if l == nil {
+		l = Logger()
+	}
+	return l.With("db", kdb.path)
+}
+
+// LogFormat selects the output of [NewLogger].
+type LogFormat uint8
+
+const (
+	LogText LogFormat = iota
+	LogJSON
+)
+
+func (f LogFormat) String() string {
+	switch f {
+	case LogText:
+		return "text"
+	case LogJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("LogFormat(%d)", f)
+	}
+}
+
+// ParseLogFormat parses "text" or "json".
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch strings.ToLower(s) {
+	case "text", "":
+		return LogText, nil
+	case "json":
+		return LogJSON, nil
+	default:
+		return 0, fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
+// NewLogger returns a logger writing records of level and above to w in the given format.
+func NewLogger(w io.Writer, format LogFormat, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// SetupCommandLogging is how the kismet_db_* commands log: it parses format, see [ParseLogFormat],
+// sets the package to log records of level and above to stderr, and returns that logger for
+// the command's own use.
+func SetupCommandLogging(format string, level slog.Level) (*slog.Logger, error) {
+	f, err := ParseLogFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	logger := NewLogger(os.Stderr, f, level)
+	SetLogger(logger)
+	return logger, nil
+}
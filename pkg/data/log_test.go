@@ -0,0 +1,138 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	prev := Logger()
+	SetLogger(NewLogger(buf, LogJSON, slog.LevelDebug))
+	t.Cleanup(func() {
+		SetLogger(prev)
+	})
+	return buf
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		rec := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestParseLogFormat(t *testing.T) {
+	for in, want := range map[string]LogFormat{"": LogText, "text": LogText, "JSON": LogJSON} {
+		got, err := ParseLogFormat(in)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s (%v)", in, want, got, err)
+		}
+	}
+	if _, err := ParseLogFormat("xml"); err == nil {
+		t.Error("expected unknown format to be rejected")
+	}
+}
+
+func TestSetupCommandLogging(t *testing.T) {
+	prev := Logger()
+	t.Cleanup(func() {
+		SetLogger(prev)
+	})
+
+	if _, err := SetupCommandLogging("xml", slog.LevelInfo); err == nil {
+		t.Error("expected unknown format to be rejected")
+	}
+	logger, err := SetupCommandLogging("json", slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if Logger() != logger {
+		t.Error("expected the command's logger to be the package's")
+	}
+	if logger.Enabled(context.Background(), slog.LevelInfo) || !logger.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("expected records below warn to be dropped")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	if Logger().Enabled(context.Background(), slog.LevelError) {
+		t.Fatal("expected the package to discard logs by default")
+	}
+
+	buf := captureLogs(t)
+
+	path := filepath.Join(t.TempDir(), "log.kismet")
+	db, err := OpenKismetDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	records := logRecords(t, buf)
+	if len(records) != 1 || records[0]["msg"] != "wrote schema" || records[0]["db"] != path {
+		t.Fatalf("unexpected records: %v", records)
+	}
+
+	own := new(bytes.Buffer)
+	db.SetLogger(NewLogger(own, LogJSON, slog.LevelDebug))
+	buf.Reset()
+
+	seedTestDatabase(t, db, "00:11:22:33:44:55", 3)
+	if _, err = db.FindRelatedMacs("00:11:22:33:44:55"); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("expected the database logger to override the package logger, got %s", buf.String())
+	}
+	records = logRecords(t, own)
+	if len(records) == 0 || records[0]["msg"] != "related mac" || records[0]["mac"] != "00:11:22:33:44:55" {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
+
+func TestMergeLogFields(t *testing.T) {
+	buf := captureLogs(t)
+
+	target := newTestDatabase(t, "target.kismet")
+	source := newTestDatabase(t, "source.kismet")
+	seedTestDatabase(t, source, "00:11:22:33:44:55", 5)
+
+	if err := MergeKismetDatabasesOpts(target, &MergeOptions{Tidy: TidyNever}, source.path); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks int
+	for _, rec := range logRecords(t, buf) {
+		if rec["msg"] != "copied chunk" {
+			continue
+		}
+		chunks++
+		for _, field := range []string{"db", "source", "alias", "table", "rows"} {
+			if _, ok := rec[field]; !ok {
+				t.Errorf("chunk record missing %s: %v", field, rec)
+			}
+		}
+	}
+	if chunks == 0 {
+		t.Error("expected chunk progress at debug level")
+	}
+}
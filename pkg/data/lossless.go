@@ -0,0 +1,126 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+)
+
+// Kismet logs far more of a device than [Device] models. The modelled types keep what they
+// don't model as raw JSON, and the raw JSON of what they do, so that a parsed device can be
+// edited and encoded again without losing anything:
+//
+//   - keys the type has no field for are kept as they were and written back as is
+//   - a field that is unchanged since decoding is written back as the JSON it was decoded
+//     from, so that keys nested in it that the type doesn't model survive too
+//   - a field whose key was absent is only written if it has since been given a value
+
+// jsonField is a struct field that maps to a key of a JSON object.
+type jsonField struct {
+	index int
+	name  string
+}
+
+var jsonFieldCache sync.Map // reflect.Type -> []jsonField
+
+func jsonFields(t reflect.Type) []jsonField {
+	if cached, ok := jsonFieldCache.Load(t); ok {
+		return cached.([]jsonField)
+	}
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, jsonField{index: i, name: name})
+	}
+	jsonFieldCache.Store(t, fields)
+	return fields
+}
+
+// decodeLossless decodes the JSON object b into v, a pointer to a struct without JSON methods
+// of its own, and returns the raw JSON of the keys v has no field for and of those it has.
+func decodeLossless(b []byte, v any) (unknown, known map[string]json.RawMessage, err error) {
+	var raw map[string]json.RawMessage
+	if err = sonic.Unmarshal(b, &raw); err != nil {
+		return nil, nil, err
+	}
+	if err = sonic.Unmarshal(b, v); err != nil {
+		return nil, nil, err
+	}
+
+	known = make(map[string]json.RawMessage)
+	for _, f := range jsonFields(reflect.TypeOf(v).Elem()) {
+		if r, ok := raw[f.name]; ok {
+			known[f.name] = r
+			delete(raw, f.name)
+		}
+	}
+	if len(raw) > 0 {
+		unknown = raw
+	}
+
+	return unknown, known, nil
+}
+
+// encodeLossless encodes v, a struct without JSON methods of its own, along with the raw JSON
+// kept by [decodeLossless]. A nil known means v was not decoded, and every field is written.
+func encodeLossless(v any, unknown, known map[string]json.RawMessage) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	out := make(map[string]json.RawMessage, len(unknown)+len(known))
+
+	for _, f := range jsonFields(rv.Type()) {
+		fv := rv.Field(f.index)
+		r, ok := known[f.name]
+
+		switch {
+		case ok:
+			// a field Kismet logged that hasn't been changed is written back as logged
+			fresh := reflect.New(fv.Type())
+			if sonic.Unmarshal(r, fresh.Interface()) == nil && reflect.DeepEqual(fresh.Elem().Interface(), fv.Interface()) {
+				out[f.name] = r
+				continue
+			}
+		case known != nil && isEmptyValue(fv):
+			// don't add keys that Kismet didn't log
+			continue
+		}
+
+		b, err := sonic.ConfigStd.Marshal(fv.Interface())
+		if err != nil {
+			return nil, err
+		}
+		out[f.name] = b
+	}
+
+	for k, r := range unknown {
+		out[k] = r
+	}
+
+	return sonic.ConfigStd.Marshal(out)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	case reflect.Struct:
+		// such as a Dot11 with the empty client map of NewDevice, on a device of another phy
+		for i := 0; i < v.NumField(); i++ {
+			if !isEmptyValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,404 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MACIndexFile is the name of the sidecar index [OpenMACIndex] keeps in a log directory.
+const MACIndexFile = ".kismet2mdk_macs.sqlite"
+
+//goland:noinspection SqlNoDataSourceInspection
+const macIndexSchema = `
+CREATE TABLE IF NOT EXISTS files (path TEXT PRIMARY KEY, size INT, mtime INT, indexed_at INT);
+CREATE TABLE IF NOT EXISTS presence (mac TEXT, phyname TEXT, file TEXT, first_ts INT, last_ts INT, packets INT, PRIMARY KEY(mac, phyname, file)) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS presence_file ON presence (file);
+`
+
+// macPresenceQuery aggregates every MAC address seen in the attached log "src", whether as a
+// packet's source, destination or transmitter, or as a device. Broadcast and null addresses are skipped.
+// A packet naming an address more than once, as an AP's frames do with their source and
+// transmitter, counts once for it.
+//
+//goland:noinspection SqlResolve
+const macPresenceQuery = `
+INSERT INTO presence (mac, phyname, file, first_ts, last_ts, packets)
+SELECT mac, phyname, ?, min(first_ts), max(last_ts), sum(packets) FROM (
+	SELECT mac, phyname, min(ts_sec) AS first_ts, max(ts_sec) AS last_ts, count(*) AS packets FROM (
+		SELECT rowid, upper(sourcemac) AS mac, phyname, ts_sec FROM src.packets
+		UNION SELECT rowid, upper(destmac), phyname, ts_sec FROM src.packets
+		UNION SELECT rowid, upper(transmac), phyname, ts_sec FROM src.packets
+	) GROUP BY 1, 2
+	UNION ALL SELECT upper(devmac), phyname, nullif(first_time, 0), nullif(last_time, 0), 0 FROM src.devices
+) WHERE mac IS NOT NULL AND mac NOT IN ('', '00:00:00:00:00:00', 'FF:FF:FF:FF:FF:FF')
+GROUP BY mac, phyname
+`
+
+// MACPresence records that a MAC address was seen in a log.
+type MACPresence struct {
+	MAC  string
+	Phy  string
+	File string
+	// First and Last are the earliest and latest times the address was seen in File.
+	First time.Time
+	Last  time.Time
+	// Packets counts the packets the address sent, received or relayed.
+	Packets int64
+}
+
+func (mp *MACPresence) String() string {
+	return fmt.Sprintf("%s %s: %s, %s - %s, %d packets", mp.Phy, mp.MAC, mp.File,
+		mp.First.UTC().Format(time.RFC3339), mp.Last.UTC().Format(time.RFC3339), mp.Packets)
+}
+
+// MACIndexUpdate summarises a call to [MACIndex.Update].
+type MACIndexUpdate struct {
+	Added     []string
+	Refreshed []string
+	Removed   []string
+	Unchanged int
+	// Failed holds the logs that couldn't be indexed, such as corrupt ones, and why. They
+	// keep what was indexed of them before, if anything, and are retried by the next update.
+	Failed map[string]error
+}
+
+func (miu *MACIndexUpdate) String() string {
+	return fmt.Sprintf("%d added, %d refreshed, %d removed, %d unchanged, %d failed",
+		len(miu.Added), len(miu.Refreshed), len(miu.Removed), miu.Unchanged, len(miu.Failed))
+}
+
+// MACIndex maps MAC addresses to the Kismet logs in a directory that saw them, so that
+// "have we ever seen this MAC" doesn't need to open every log.
+type MACIndex struct {
+	dir  string
+	conn *sql.DB
+}
+
+// OpenMACIndex opens, or creates, the [MACIndexFile] in dir. Call [MACIndex.Update] to pick up new logs.
+func OpenMACIndex(dir string) (*MACIndex, error) {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("mac index dir: %w", err)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("fs: %s is not a directory", dir)
+	}
+
+	mi := &MACIndex{dir: dir}
+	if mi.conn, err = sql.Open("sqlite", filepath.Join(dir, MACIndexFile)); err != nil {
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
+	}
+	// attachments are per connection
+	mi.conn.SetMaxOpenConns(1)
+
+	if _, err = mi.conn.Exec(macIndexSchema); err != nil {
+		_ = mi.conn.Close()
+		return nil, fmt.Errorf("sql, failed to assure mac index schema: %w", wrapSQLiteError(err))
+	}
+
+	return mi, nil
+}
+
+func (mi *MACIndex) String() string {
+	return filepath.Join(mi.dir, MACIndexFile)
+}
+
+// logs returns the Kismet logs under the index directory, relative to it.
+func (mi *MACIndex) logs() (map[string]fs.FileInfo, error) {
+	found := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(mi.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".kismet") {
+			return nil
+		}
+		info, infoErr := d.Info()
+		if infoErr != nil {
+			return infoErr
+		}
+		rel, relErr := filepath.Rel(mi.dir, path)
+		if relErr != nil {
+			return relErr
+		}
+		found[filepath.ToSlash(rel)] = info
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s for kismet logs: %w", mi.dir, err)
+	}
+	return found, nil
+}
+
+// Update indexes logs that are new or changed size or modification time since they were last
+// indexed, and forgets logs that are gone. Every log is indexed in its own transaction, so an
+// interrupted update keeps the progress it made. A log that can't be indexed doesn't stop the
+// update, see [MACIndexUpdate.Failed].
+//
+//goland:noinspection SqlResolve
+func (mi *MACIndex) Update(ctx context.Context) (*MACIndexUpdate, error) {
+	found, err := mi.logs()
+	if err != nil {
+		return nil, err
+	}
+
+	type indexed struct{ size, mtime int64 }
+	known := make(map[string]indexed)
+
+	rows, err := mi.conn.QueryContext(ctx, "SELECT path, size, mtime FROM files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexed files: %w", wrapSQLiteError(err))
+	}
+	for rows.Next() {
+		var (
+			path string
+			ix   indexed
+		)
+		if err = rows.Scan(&path, &ix.size, &ix.mtime); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan indexed file: %w", wrapSQLiteError(err))
+		}
+		known[path] = ix
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read indexed files: %w", wrapSQLiteError(err))
+	}
+
+	update := &MACIndexUpdate{}
+
+	for path := range known {
+		if _, ok := found[path]; ok {
+			continue
+		}
+		if err = mi.forget(ctx, path); err != nil {
+			return update, err
+		}
+		update.Removed = append(update.Removed, path)
+	}
+
+	paths := make([]string, 0, len(found))
+	for path := range found {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		info := found[path]
+		ix, ok := known[path]
+		if ok && ix.size == info.Size() && ix.mtime == info.ModTime().UnixNano() {
+			update.Unchanged++
+			continue
+		}
+		if err = mi.index(ctx, path, info); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return update, ctxErr
+			}
+			Logger().Warn("failed to index log", "dir", mi.dir, "source", path, "err", err)
+			if update.Failed == nil {
+				update.Failed = make(map[string]error)
+			}
+			update.Failed[path] = err
+			continue
+		}
+		if ok {
+			update.Refreshed = append(update.Refreshed, path)
+		} else {
+			update.Added = append(update.Added, path)
+		}
+	}
+
+	slices.Sort(update.Removed)
+
+	return update, nil
+}
+
+//goland:noinspection SqlResolve
+func (mi *MACIndex) forget(ctx context.Context, path string) error {
+	tx, err := mi.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", wrapSQLiteError(err))
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM presence WHERE file = ?", path)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "DELETE FROM files WHERE path = ?", path)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to drop %s from mac index: %w", path, wrapSQLiteError(err))
+	}
+	return nil
+}
+
+//goland:noinspection SqlResolve
+func (mi *MACIndex) index(ctx context.Context, path string, info fs.FileInfo) (err error) {
+	uri, err := readOnlyURI(filepath.Join(mi.dir, filepath.FromSlash(path)))
+	if err != nil {
+		return err
+	}
+
+	Logger().Info("indexing", "dir", mi.dir, "source", path)
+
+	if _, err = mi.conn.ExecContext(ctx, attachQuery(uri, "src")); err != nil {
+		return fmt.Errorf("failed to attach %s: %w", path, wrapSQLiteError(err))
+	}
+	defer func() {
+		if _, detachErr := mi.conn.ExecContext(context.Background(), detachQuery("src")); detachErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to detach %s: %w", path, wrapSQLiteError(detachErr)))
+		}
+	}()
+
+	tx, err := mi.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", wrapSQLiteError(err))
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM presence WHERE file = ?", path)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, macPresenceQuery, path)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			"INSERT OR REPLACE INTO files (path, size, mtime, indexed_at) VALUES (?, ?, ?, ?)",
+			path, info.Size(), info.ModTime().UnixNano(), time.Now().Unix(),
+		)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to index %s: %w", path, wrapSQLiteError(err))
+	}
+
+	return nil
+}
+
+// normalizeMAC returns mac, in any of the forms [net.ParseMAC] accepts, as Kismet logs it:
+// six colon separated, upper case bytes.
+func normalizeMAC(mac string) (string, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	if len(hw) != 6 {
+		return "", fmt.Errorf("not a 6 byte mac: %s", mac)
+	}
+	return strings.ToUpper(hw.String()), nil
+}
+
+// Lookup returns every indexed log that saw mac, earliest first.
+//
+//goland:noinspection SqlResolve
+func (mi *MACIndex) Lookup(ctx context.Context, mac string) ([]*MACPresence, error) {
+	mac, err := normalizeMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mi.conn.QueryContext(ctx,
+		"SELECT mac, phyname, file, ifnull(first_ts, 0), ifnull(last_ts, 0), packets FROM presence WHERE mac = ? ORDER BY first_ts, file",
+		mac,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mac index: %w", wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var found []*MACPresence
+	for rows.Next() {
+		var (
+			mp          = new(MACPresence)
+			first, last int64
+		)
+		if err = rows.Scan(&mp.MAC, &mp.Phy, &mp.File, &first, &last, &mp.Packets); err != nil {
+			return nil, fmt.Errorf("failed to scan mac presence: %w", wrapSQLiteError(err))
+		}
+		mp.First, mp.Last = time.Unix(first, 0), time.Unix(last, 0)
+		found = append(found, mp)
+	}
+
+	return found, wrapSQLiteError(rows.Err())
+}
+
+// FindRelatedMacs works like [KismetDatabase.FindRelatedMacs] across every indexed log,
+// but only opens the logs that the index says saw mac.
+func (mi *MACIndex) FindRelatedMacs(ctx context.Context, mac string) ([]string, error) {
+	found, err := mi.Lookup(ctx, mac)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+
+	var files []string
+	for _, mp := range found {
+		file := filepath.Join(mi.dir, filepath.FromSlash(mp.File))
+		if !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+
+	fed, err := NewFederation(0, files...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fed.Close()
+	}()
+
+	rows, err := fed.Query(ctx, &FederatedQuery{
+		Query: fmt.Sprintf(queryfmt, FederatedSourcePlaceholder+".packets"),
+		Args:  []any{found[0].MAC, found[0].MAC},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var (
+		related   = make([]string, 0)
+		parseErrs []error
+	)
+
+	for rows.Next() {
+		var source, addr string
+		if err = rows.Scan(&source, &addr); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", wrapSQLiteError(err))
+		}
+		if _, validErr := net.ParseMAC(addr); validErr != nil {
+			parseErrs = append(parseErrs, fmt.Errorf("ignored seemingly invalid mac in %s: %s", source, addr))
+			continue
+		}
+		if !slices.Contains(related, addr) {
+			related = append(related, addr)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, wrapSQLiteError(err)
+	}
+
+	return related, errors.Join(parseErrs...)
+}
+
+func (mi *MACIndex) Close() error {
+	if err := mi.conn.Close(); err != nil {
+		return fmt.Errorf("failed to close mac index: %w", wrapSQLiteError(err))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMACIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	newLog := func(name, mac string, packets int) {
+		db, err := OpenKismetDatabase(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		seedTestDatabase(t, db, mac, packets)
+		_ = db.Close()
+	}
+
+	newLog("one.kismet", "00:11:22:33:44:01", 3)
+	newLog("two.kismet", "00:11:22:33:44:02", 2)
+	if err := os.WriteFile(filepath.Join(dir, "bad.kismet"), []byte("not a database, not even close"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	idx, err := OpenMACIndex(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	ctx := context.Background()
+
+	update, err := idx.Update(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(update.Added) != 2 || len(update.Failed) != 1 || update.Failed["bad.kismet"] == nil {
+		t.Errorf("expected 2 logs added and 1 failed, got %s", update)
+	}
+
+	// any form of the address finds it
+	found, err := idx.Lookup(ctx, "0011.2233.4401")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(found) != 1 || found[0].File != "one.kismet" || found[0].Packets != 3 || found[0].Last.Unix() != 2 {
+		t.Fatalf("unexpected presence: %v", found)
+	}
+
+	found, err = idx.Lookup(ctx, "ff:ff:ff:ff:ff:ff")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(found) != 0 {
+		t.Errorf("broadcast address should not be indexed: %v", found)
+	}
+
+	related, err := idx.FindRelatedMacs(ctx, "00:11:22:33:44:01")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !slices.Equal(related, []string{"FF:FF:FF:FF:FF:FF"}) {
+		t.Errorf("unexpected related macs: %v", related)
+	}
+
+	newLog("three.kismet", "00:11:22:33:44:01", 1)
+	if err = os.Remove(filepath.Join(dir, "two.kismet")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if update, err = idx.Update(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(update.Added) != 1 || len(update.Removed) != 1 || update.Unchanged != 1 || len(update.Failed) != 1 {
+		t.Errorf("unexpected incremental update: %s", update)
+	}
+
+	if found, err = idx.Lookup(ctx, "00:11:22:33:44:01"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(found) != 2 {
+		t.Errorf("expected mac in two logs, got %v", found)
+	}
+	if found, _ = idx.Lookup(ctx, "00:11:22:33:44:02"); len(found) != 0 {
+		t.Errorf("removed log still indexed: %v", found)
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	for mac, want := range map[string]string{
+		"00:11:22:aa:bb:cc": "00:11:22:AA:BB:CC",
+		"00-11-22-AA-BB-CC": "00:11:22:AA:BB:CC",
+		"0011.22aa.bbcc":    "00:11:22:AA:BB:CC",
+		// EUI-64 and InfiniBand addresses aren't logged by Kismet
+		"00:11:22:33:44:55:66:77": "",
+		"00:11:22":                "",
+		"not a mac":               "",
+	} {
+		got, err := normalizeMAC(mac)
+		if got != want || (err != nil) != (want == "") {
+			t.Errorf("%s: expected %q, got %q (%v)", mac, want, got, err)
+		}
+	}
+}
+
+func TestMACIndexCountsPacketsOnce(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := OpenKismetDatabase(filepath.Join(dir, "ap.kismet"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	seedTestDatabase(t, db, "00:11:22:33:44:01", 0)
+	// an AP's frames name it as both their source and transmitter
+	for i := 0; i < 3; i++ {
+		//goland:noinspection SqlResolve
+		if _, err = db.conn.Exec(
+			"INSERT INTO packets (ts_sec, ts_usec, phyname, sourcemac, destmac, transmac, datasource, packetid) VALUES (?, 0, 'IEEE802.11', '00:11:22:33:44:01', 'FF:FF:FF:FF:FF:FF', '00:11:22:33:44:01', 'test', ?)",
+			i, i,
+		); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	_ = db.Close()
+
+	idx, err := OpenMACIndex(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		_ = idx.Close()
+	}()
+
+	ctx := context.Background()
+	if _, err = idx.Update(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	found, err := idx.Lookup(ctx, "00:11:22:33:44:01")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(found) != 1 || found[0].Packets != 3 {
+		t.Fatalf("expected 3 packets, got %v", found)
+	}
+}
@@ -2,43 +2,26 @@ package data
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"net"
-
-	"github.com/l0nax/go-spew/spew"
 )
 
-const queryfmt = `SELECT DISTINCT * FROM (SELECT sourcemac FROM packets WHERE destmac = ? UNION SELECT destmac FROM packets WHERE sourcemac = ?);`
+const queryfmt = `SELECT DISTINCT * FROM (SELECT sourcemac FROM %[1]s WHERE destmac = ? UNION SELECT destmac FROM %[1]s WHERE sourcemac = ?)`
 
 func (kdb *KismetDatabase) FindRelatedMacs(mac string) ([]string, error) {
 	return kdb.FindRelatedMacsCtx(context.Background(), mac)
 }
 
-func debugRows(rows *sql.Rows) {
-	cols, err := rows.Columns()
-	if err != nil {
-		panic(err.Error())
-	}
-	spew.Dump(cols)
-	colTypes, err := rows.ColumnTypes()
-	if err != nil {
-		panic(err.Error())
-	}
-	spew.Dump(colTypes)
-
-}
-
 func (kdb *KismetDatabase) FindRelatedMacsCtx(ctx context.Context, mac string) ([]string, error) {
 	if _, err := net.ParseMAC(mac); err != nil {
 		return nil, err
 	}
 
 	//goland:noinspection SqlResolve
-	rows, err := kdb.conn.QueryContext(ctx, queryfmt, mac, mac)
+	rows, err := kdb.reader.QueryContext(ctx, fmt.Sprintf(queryfmt, "packets"), mac, mac)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query database: %w", err)
+		return nil, fmt.Errorf("failed to query database: %w", wrapSQLiteError(err))
 	}
 
 	defer func() {
@@ -49,16 +32,14 @@ func (kdb *KismetDatabase) FindRelatedMacsCtx(ctx context.Context, mac string) (
 
 	var parseErrs []error
 
-	debugRows(rows)
-
 	for rows.Next() {
 
 		if err = rows.Err(); err != nil {
-			return nil, err
+			return nil, wrapSQLiteError(err)
 		}
 		var addr string
 		if err = rows.Scan(&addr); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return nil, fmt.Errorf("failed to scan row: %w", wrapSQLiteError(err))
 		}
 		if addr == "" {
 			parseErrs = append(parseErrs, errors.New("blank value was present"))
@@ -68,7 +49,7 @@ func (kdb *KismetDatabase) FindRelatedMacsCtx(ctx context.Context, mac string) (
 			parseErrs = append(parseErrs, fmt.Errorf("ignored seemingly invalid mac: %s", addr))
 			continue
 		}
-		println(addr)
+		kdb.Logger().Debug("related mac", "mac", mac, "related", addr)
 		related = append(related, addr)
 	}
 
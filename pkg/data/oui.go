@@ -0,0 +1,324 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// oui.csv is the registry embedded in the package. Regenerate it from IEEE's registries with
+//
+//go:generate go run ../../cmd/kismet_db_oui -fetch oui.csv
+//go:embed oui.csv
+var embeddedOUI []byte
+
+// IEEERegistryURLs are where IEEE publishes its MA-L, MA-M and MA-S registries.
+var IEEERegistryURLs = []string{
+	"https://standards-oui.ieee.org/oui/oui.csv",
+	"https://standards-oui.ieee.org/oui28/mam.csv",
+	"https://standards-oui.ieee.org/oui36/oui36.csv",
+}
+
+// registrationAuthority is the organization IEEE assigns the MA-L blocks it splits into MA-M
+// and MA-S blocks to. Those say nothing about the vendor of a MAC within them.
+const registrationAuthority = "IEEE Registration Authority"
+
+// ouiLengths are the lengths, in hex digits, of MA-S, MA-M and MA-L assignments, longest first.
+var ouiLengths = []int{9, 7, 6}
+
+// OUIRegistry maps the MAC address blocks IEEE assigns to the organizations they are assigned to.
+type OUIRegistry struct {
+	// assignments maps the upper case hex digits of each block to its organization
+	assignments map[string]string
+	// byOrg maps the lower case name of every organization but the registration authority to
+	// its blocks, and nested maps a block to the longer blocks within it, for
+	// [OUIRegistry.vendorBlocks]
+	byOrg  map[string][]string
+	nested map[string][]string
+}
+
+// index fills in byOrg and nested from the assignments.
+func (reg *OUIRegistry) index() {
+	reg.byOrg = make(map[string][]string)
+	reg.nested = make(map[string][]string)
+	for block, org := range reg.assignments {
+		if org == registrationAuthority {
+			continue
+		}
+		lower := strings.ToLower(org)
+		reg.byOrg[lower] = append(reg.byOrg[lower], block)
+		for _, n := range ouiLengths {
+			if n >= len(block) {
+				continue
+			}
+			if _, ok := reg.assignments[block[:n]]; ok {
+				reg.nested[block[:n]] = append(reg.nested[block[:n]], block)
+			}
+		}
+	}
+}
+
+// ParseOUIRegistry reads registries in IEEE's CSV format, that of [IEEERegistryURLs], with
+// later assignments replacing earlier ones.
+func ParseOUIRegistry(readers ...io.Reader) (*OUIRegistry, error) {
+	reg := &OUIRegistry{assignments: make(map[string]string)}
+	for _, r := range readers {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		for line := 1; ; line++ {
+			rec, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(rec) < 3 {
+				return nil, fmt.Errorf("line %d: expected registry, assignment and organization", line)
+			}
+			if line == 1 && rec[0] == "Registry" || rec[0] == "CID" {
+				// CIDs aren't used for MACs
+				continue
+			}
+			block := strings.ToUpper(strings.TrimSpace(rec[1]))
+			if !slices.Contains(ouiLengths, len(block)) || !isHex(block) {
+				continue
+			}
+			reg.assignments[block] = strings.TrimSpace(rec[2])
+		}
+	}
+	reg.index()
+	return reg, nil
+}
+
+// LoadOUIRegistry reads registries in IEEE's CSV format from files, see [ParseOUIRegistry].
+func LoadOUIRegistry(paths ...string) (*OUIRegistry, error) {
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, bytes.NewReader(b))
+	}
+	reg, err := ParseOUIRegistry(readers...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load oui registry: %w", err)
+	}
+	return reg, nil
+}
+
+// FetchOUIRegistry downloads IEEE's current registries from [IEEERegistryURLs]. A nil
+// client uses [http.DefaultClient].
+func FetchOUIRegistry(ctx context.Context, client *http.Client) (*OUIRegistry, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	readers := make([]io.Reader, 0, len(IEEERegistryURLs))
+	for _, url := range IEEERegistryURLs {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = errors.New(resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+		}
+		readers = append(readers, bytes.NewReader(b))
+	}
+	reg, err := ParseOUIRegistry(readers...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse oui registry: %w", err)
+	}
+	return reg, nil
+}
+
+// WriteCSV writes the registry in IEEE's CSV format, in the order of the assignments.
+func (reg *OUIRegistry) WriteCSV(w io.Writer) error {
+	blocks := make([]string, 0, len(reg.assignments))
+	for block := range reg.assignments {
+		blocks = append(blocks, block)
+	}
+	slices.Sort(blocks)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"Registry", "Assignment", "Organization Name", "Organization Address"}); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		registry := map[int]string{6: "MA-L", 7: "MA-M", 9: "MA-S"}[len(block)]
+		if err := cw.Write([]string{registry, block, reg.assignments[block], ""}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Len returns the number of assignments in the registry.
+func (reg *OUIRegistry) Len() int {
+	return len(reg.assignments)
+}
+
+// Lookup returns the organization that the block of mac is assigned to, by the most
+// specific assignment that covers it. MACs covered by none but the IEEE Registration
+// Authority's blocks, whose MA-M and MA-S assignments the registry lacks, aren't found.
+func (reg *OUIRegistry) Lookup(mac string) (string, bool) {
+	digits := macDigits(mac)
+	if len(digits) != 12 {
+		return "", false
+	}
+	for _, n := range ouiLengths {
+		if org, ok := reg.assignments[digits[:n]]; ok {
+			if org == registrationAuthority {
+				return "", false
+			}
+			return org, true
+		}
+	}
+	return "", false
+}
+
+// vendorBlocks are the blocks of one length assigned to a vendor, formatted as the start of
+// a MAC, and the longer blocks within them, which are assigned in their own right.
+type vendorBlocks struct {
+	owned, nested []string
+}
+
+// vendorBlocks returns, longest first, the blocks assigned to organizations whose name
+// contains text, ignoring case.
+func (reg *OUIRegistry) vendorBlocks(text string) []vendorBlocks {
+	text = strings.ToLower(text)
+	byLen := make(map[int]*vendorBlocks)
+	for org, blocks := range reg.byOrg {
+		if !strings.Contains(org, text) {
+			continue
+		}
+		for _, block := range blocks {
+			vb, ok := byLen[len(block)]
+			if !ok {
+				vb = &vendorBlocks{}
+				byLen[len(block)] = vb
+			}
+			vb.owned = append(vb.owned, formatOUI(block))
+			for _, nested := range reg.nested[block] {
+				vb.nested = append(vb.nested, formatOUI(nested))
+			}
+		}
+	}
+
+	var all []vendorBlocks
+	for _, n := range ouiLengths {
+		if vb, ok := byLen[n]; ok {
+			slices.Sort(vb.owned)
+			slices.Sort(vb.nested)
+			all = append(all, *vb)
+		}
+	}
+	return all
+}
+
+var (
+	ouiRegistry atomic.Pointer[OUIRegistry]
+
+	embeddedOUIRegistry = sync.OnceValue(func() *OUIRegistry {
+		reg, err := ParseOUIRegistry(bytes.NewReader(embeddedOUI))
+		if err != nil {
+			panic("embedded oui registry: " + err.Error())
+		}
+		return reg
+	})
+)
+
+// SetOUIRegistry sets the registry used for vendor lookups, such as one that [FetchOUIRegistry]
+// returned. A nil registry reverts to the one embedded in the package, which is the default.
+func SetOUIRegistry(reg *OUIRegistry) {
+	ouiRegistry.Store(reg)
+}
+
+// OUIRegistryInUse returns the registry set with [SetOUIRegistry], or the embedded one.
+func OUIRegistryInUse() *OUIRegistry {
+	if reg := ouiRegistry.Load(); reg != nil {
+		return reg
+	}
+	return embeddedOUIRegistry()
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789ABCDEFabcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// macDigits returns the upper case hex digits of mac, without separators.
+func macDigits(mac string) string {
+	digits := strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.TrimSpace(mac))
+	if !isHex(digits) {
+		return ""
+	}
+	return strings.ToUpper(digits)
+}
+
+// formatOUI returns the hex digits of a block as the start of a MAC, such as "00:03:93"
+// for "000393", or "70:B3:D5:0" for "70B3D50".
+func formatOUI(block string) string {
+	var sb strings.Builder
+	for i, c := range block {
+		if i > 0 && i%2 == 0 {
+			sb.WriteByte(':')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// IsRandomizedMAC reports whether mac is a locally administered unicast address, as the
+// randomized MACs that phones and laptops probe and connect with are. Such addresses aren't
+// assigned by IEEE, so say nothing about the vendor.
+func IsRandomizedMAC(mac string) bool {
+	digits := macDigits(mac)
+	if len(digits) != 12 {
+		return false
+	}
+	// the locally administered bit set, the multicast bit clear
+	return strings.ContainsRune("26AE", rune(digits[1]))
+}
+
+// unknownManuf is what Kismet logs as the manufacturer of a MAC it has no vendor for.
+const unknownManuf = "Unknown"
+
+// Enrich sets the fields of d derived from its MAC, using reg or, if nil, [OUIRegistryInUse].
+// [Parse] enriches every device with the registry in use.
+func (d *Device) Enrich(reg *OUIRegistry) {
+	if reg == nil {
+		reg = OUIRegistryInUse()
+	}
+	d.RandomizedMAC = IsRandomizedMAC(d.BaseMacaddr)
+	d.Vendor = ""
+	if org, ok := reg.Lookup(d.BaseMacaddr); ok {
+		d.Vendor = org
+	} else if d.BaseManuf != unknownManuf {
+		d.Vendor = d.BaseManuf
+	}
+}
@@ -0,0 +1,222 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const testRegistry = `Registry,Assignment,Organization Name,Organization Address
+MA-L,001122,"Acme, Inc.",Somewhere
+MA-M,0011223,Acme Subsidiary,Elsewhere
+MA-S,001122334,Tiny Corp,
+CID,0A1B2C,Not For MACs,
+MA-L,70B3D5,IEEE Registration Authority,
+`
+
+func TestOUIRegistry(t *testing.T) {
+	reg, err := ParseOUIRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.Len() != 4 {
+		t.Fatalf("expected 4 assignments, got %d", reg.Len())
+	}
+
+	for mac, want := range map[string]string{
+		"00:11:22:33:44:55": "Tiny Corp",
+		"00:11:22:33:55:55": "Acme Subsidiary",
+		"00-11-22-44-55-66": "Acme, Inc.",
+		"001122ffffff":      "Acme, Inc.",
+		"0a:1b:2c:00:00:00": "",
+		// the registration authority's own block doesn't name a vendor
+		"70:b3:d5:00:00:01": "",
+		"00:11:22":          "",
+		"not a mac":         "",
+	} {
+		got, ok := reg.Lookup(mac)
+		if got != want || ok != (want != "") {
+			t.Errorf("%s: expected %q, got %q", mac, want, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = reg.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseOUIRegistry(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Len() != reg.Len() {
+		t.Fatalf("expected %d assignments after writing, got %d", reg.Len(), again.Len())
+	}
+	if got, _ := again.Lookup("00:11:22:33:44:55"); got != "Tiny Corp" {
+		t.Errorf("expected Tiny Corp after writing, got %q", got)
+	}
+
+	if _, err = ParseOUIRegistry(strings.NewReader("MA-L,001122\n")); err == nil {
+		t.Error("expected an error for a record without an organization")
+	}
+}
+
+func TestEmbeddedOUIRegistry(t *testing.T) {
+	counts := make(map[int]int)
+	for block := range embeddedOUIRegistry().assignments {
+		counts[len(block)]++
+	}
+	if counts[6] < 20000 {
+		t.Fatalf("expected tens of thousands of MA-L assignments, got %d", counts[6])
+	}
+	t.Run("MA-M and MA-S", func(t *testing.T) {
+		if counts[7] == 0 && counts[9] == 0 {
+			t.Skip("the embedded registry holds no MA-M or MA-S assignments, regenerate it with go generate")
+		}
+		if counts[7] == 0 || counts[9] == 0 {
+			t.Errorf("expected MA-M and MA-S assignments, got %d and %d", counts[7], counts[9])
+		}
+	})
+	if got, _ := OUIRegistryInUse().Lookup("00:03:93:00:00:01"); got != "Apple, Inc." {
+		t.Errorf("expected Apple, Inc., got %q", got)
+	}
+
+	reg, err := ParseOUIRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetOUIRegistry(reg)
+	if OUIRegistryInUse() != reg {
+		t.Error("expected the registry set to be in use")
+	}
+	SetOUIRegistry(nil)
+	if OUIRegistryInUse() != embeddedOUIRegistry() {
+		t.Error("expected the embedded registry to be in use after reverting")
+	}
+}
+
+func TestIsRandomizedMAC(t *testing.T) {
+	for mac, want := range map[string]bool{
+		"00:11:22:33:44:55": false,
+		"02:11:22:33:44:55": true,
+		"DA:A1:19:00:00:01": true,
+		"a6:00:00:00:00:00": true,
+		"ee:00:00:00:00:00": true,
+		// multicast, not randomized
+		"03:00:00:00:00:00": false,
+		"01:00:5e:00:00:01": false,
+		"":                  false,
+	} {
+		if got := IsRandomizedMAC(mac); got != want {
+			t.Errorf("%q: expected %t, got %t", mac, want, got)
+		}
+	}
+}
+
+func TestDeviceEnrich(t *testing.T) {
+	reg, err := ParseOUIRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		mac, manuf string
+		vendor     string
+		randomized bool
+	}{
+		"registered":   {"00:11:22:44:55:66", "Unknown", "Acme, Inc.", false},
+		"manuf":        {"00:AA:BB:00:00:00", "Kismet's Vendor", "Kismet's Vendor", false},
+		"unknown":      {"00:AA:BB:00:00:00", "Unknown", "", false},
+		"randomized":   {"DA:A1:19:00:00:01", "Unknown", "", true},
+		"over ieee's":  {"00:11:22:44:55:66", "Stale Name", "Acme, Inc.", false},
+		"longest wins": {"00:11:22:33:44:55", "", "Tiny Corp", false},
+		"umbrella":     {"70:B3:D5:01:02:03", "Kismet's Vendor", "Kismet's Vendor", false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := NewDevice()
+			d.BaseMacaddr, d.BaseManuf = tc.mac, tc.manuf
+			d.Enrich(reg)
+			if d.Vendor != tc.vendor || d.RandomizedMAC != tc.randomized {
+				t.Errorf("expected %q randomized %t, got %q randomized %t", tc.vendor, tc.randomized, d.Vendor, d.RandomizedMAC)
+			}
+		})
+	}
+}
+
+func TestDevicesByVendor(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "vendor.kismet")
+
+	reg, err := ParseOUIRegistry(strings.NewReader(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetOUIRegistry(reg)
+	t.Cleanup(func() {
+		SetOUIRegistry(nil)
+	})
+
+	for _, mac := range []string{"00:11:22:33:44:55", "00:11:22:33:55:55", "00:11:22:44:55:66", "da:a1:19:00:00:01", "02:00:00:00:00:01", "70:b3:d5:00:00:01"} {
+		insertTestDevice(t, db, PhyDot11, mac, `{"kismet.device.base.macaddr": "`+strings.ToUpper(mac)+`", "kismet.device.base.manuf": "Unknown"}`)
+	}
+
+	yes, no := true, false
+	for name, tc := range map[string]struct {
+		query *DeviceQuery
+		want  int
+	}{
+		"vendor":         {&DeviceQuery{Vendor: "acme"}, 2},
+		"ma-s vendor":    {&DeviceQuery{Vendor: "tiny"}, 1},
+		"no vendor":      {&DeviceQuery{Vendor: "nobody"}, 0},
+		"randomized":     {&DeviceQuery{Randomized: &yes}, 2},
+		"not randomized": {&DeviceQuery{Randomized: &no}, 4},
+		"both":           {&DeviceQuery{Vendor: "acme", Randomized: &yes}, 0},
+		"nested":         {&DeviceQuery{Vendor: "acme subsidiary"}, 1},
+		"umbrella":       {&DeviceQuery{Vendor: "registration authority"}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			iter, err := db.Devices(ctx, tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			devices, err := iter.All()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(devices) != tc.want {
+				t.Fatalf("expected %d devices, got %d", tc.want, len(devices))
+			}
+			for _, d := range devices {
+				if tc.query.Randomized != nil && d.RandomizedMAC != *tc.query.Randomized {
+					t.Errorf("%s: unexpected randomized %t", d.BaseMacaddr, d.RandomizedMAC)
+				}
+				if tc.query.Vendor != "" && !strings.Contains(strings.ToLower(d.Vendor), tc.query.Vendor) {
+					t.Errorf("%s: unexpected vendor %q", d.BaseMacaddr, d.Vendor)
+				}
+			}
+		})
+	}
+}
+
+func TestDevicesByBroadVendor(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "vendor.kismet")
+
+	insertTestDevice(t, db, PhyDot11, "00:03:93:00:00:01", `{"kismet.device.base.macaddr": "00:03:93:00:00:01"}`)
+	insertTestDevice(t, db, PhyDot11, "B8:27:EB:00:00:01", `{"kismet.device.base.macaddr": "B8:27:EB:00:00:01"}`)
+
+	// vendors matching most of the registry, whose blocks can outnumber the variables sqlite
+	// allows in a statement
+	for text, want := range map[string]int{"apple": 1, "inc": 1, "a": 2, "e": 2} {
+		iter, err := db.Devices(ctx, &DeviceQuery{Vendor: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		devices, err := iter.All()
+		if err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		if len(devices) != want {
+			t.Errorf("%s: expected %d devices, got %d", text, want, len(devices))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+
+	"github.com/bytedance/sonic"
+)
+
+// Phy names, as Kismet logs them in kismet.device.base.phyname.
+const (
+	PhyDot11     = "IEEE802.11"
+	PhyBluetooth = "Bluetooth"
+	PhyBTLE      = "BTLE"
+	PhyRTL433    = "RTL433"
+	PhyADSB      = "ADSB"
+	PhyAMR       = "RTLAMR"
+	PhyUAV       = "UAV"
+)
+
+// phyRecord is the record in which a phy logs its own view of a device, next to the
+// kismet.device.base keys common to every phy.
+type phyRecord struct {
+	key string
+	// field names the pointer field of Device the record is decoded into, for the records that
+	// are only decoded for devices of their phy. Dot11 predates this, and is always decoded.
+	field string
+}
+
+var phyRecords = map[string]phyRecord{
+	PhyDot11:     {key: "dot11.device"},
+	PhyBluetooth: {key: "bluetooth.device", field: "Bluetooth"},
+	PhyBTLE:      {key: "btle.device", field: "BTLE"},
+	PhyRTL433:    {key: "rtl433.device", field: "RTL433"},
+	PhyADSB:      {key: "adsb.device", field: "ADSB"},
+	PhyAMR:       {key: "rtlamr.device", field: "AMR"},
+	PhyUAV:       {key: "uav.device", field: "UAV"},
+}
+
+// decodePhy decodes the record of the device's phy, which [decodeLossless] leaves in Unknown.
+func (d *Device) decodePhy() error {
+	rec, ok := phyRecords[d.BasePhyname]
+	if !ok || rec.field == "" {
+		return nil
+	}
+	raw, ok := d.Unknown[rec.key]
+	if !ok {
+		return nil
+	}
+
+	fv := reflect.ValueOf(d).Elem().FieldByName(rec.field)
+	v := reflect.New(fv.Type().Elem())
+	if err := sonic.Unmarshal(raw, v.Interface()); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", rec.key, err)
+	}
+	fv.Set(v)
+
+	delete(d.Unknown, rec.key)
+	if len(d.Unknown) == 0 {
+		d.Unknown = nil
+	}
+	return nil
+}
+
+// phyUnknown returns Unknown with the decoded phy records of d put back.
+func (d Device) phyUnknown() (map[string]json.RawMessage, error) {
+	unknown := d.Unknown
+	cloned := false
+	for _, rec := range phyRecords {
+		if rec.field == "" {
+			continue
+		}
+		fv := reflect.ValueOf(d).FieldByName(rec.field)
+		if fv.IsNil() {
+			continue
+		}
+		b, err := sonic.ConfigStd.Marshal(fv.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", rec.key, err)
+		}
+		if !cloned {
+			if unknown = maps.Clone(unknown); unknown == nil {
+				unknown = make(map[string]json.RawMessage)
+			}
+			cloned = true
+		}
+		unknown[rec.key] = b
+	}
+	return unknown, nil
+}
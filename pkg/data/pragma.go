@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -18,6 +19,7 @@ const (
 	PragmaJournalMode      Pragma = "journal_mode"
 	PragmaSynchronous      Pragma = "synchronous"
 	PragmaJournalSizeLimit Pragma = "journal_size_limit"
+	PragmaAutoVacuum       Pragma = "auto_vacuum"
 )
 
 func (p Pragma) String() string { return string(p) }
@@ -27,6 +29,11 @@ func (p Pragma) SetQuery(v string) string {
 }
 
 func (kdb *KismetDatabase) RestorePragma(s Pragma, butnot ...string) error {
+	return kdb.RestorePragmaCtx(context.Background(), s, butnot...)
+}
+
+// RestorePragmaCtx is [KismetDatabase.RestorePragma] with a context.
+func (kdb *KismetDatabase) RestorePragmaCtx(ctx context.Context, s Pragma, butnot ...string) error {
 	var old string
 	if old = kdb.checkBackupPragma(s); old == "" {
 		return errors.New("no backup pragma found")
@@ -38,7 +45,7 @@ func (kdb *KismetDatabase) RestorePragma(s Pragma, butnot ...string) error {
 			}
 		}
 	}
-	_, err := kdb.conn.Exec(s.SetQuery(old))
+	err := kdb.exec(ctx, s.String(), s.SetQuery(old))
 	if err == nil {
 		kdb.clearPragmaBackup(s)
 	}
@@ -46,57 +53,81 @@ func (kdb *KismetDatabase) RestorePragma(s Pragma, butnot ...string) error {
 }
 
 func (kdb *KismetDatabase) EnableWAL(b bool) error {
+	return kdb.EnableWALCtx(context.Background(), b)
+}
+
+// EnableWALCtx is [KismetDatabase.EnableWAL] with a context.
+func (kdb *KismetDatabase) EnableWALCtx(ctx context.Context, b bool) error {
 	if b {
 		if stored := kdb.checkBackupPragma(PragmaJournalMode); stored != "" && stored != "WAL" {
 			return errors.New("WAL mode (should be) enabled already")
 		}
-		if err := kdb.backupPragma(PragmaJournalMode); err != nil {
+		if err := kdb.backupPragma(ctx, PragmaJournalMode); err != nil {
 			return err
 		}
-		_, err := kdb.conn.Exec(PragmaJournalMode.SetQuery("WAL"))
-		return err
+		return kdb.exec(ctx, PragmaJournalMode.String(), PragmaJournalMode.SetQuery("WAL"))
 	}
 	var err error
-	if err = kdb.RestorePragma(PragmaJournalMode, "WAL"); err != nil {
-		_, err = kdb.conn.Exec(PragmaJournalMode.SetQuery("DELETE"))
+	if err = kdb.RestorePragmaCtx(ctx, PragmaJournalMode, "WAL"); err != nil {
+		err = kdb.exec(ctx, PragmaJournalMode.String(), PragmaJournalMode.SetQuery("DELETE"))
 	}
 	return err
 }
 
 func (kdb *KismetDatabase) EnableAsync(b bool) error {
+	return kdb.EnableAsyncCtx(context.Background(), b)
+}
+
+// EnableAsyncCtx is [KismetDatabase.EnableAsync] with a context.
+func (kdb *KismetDatabase) EnableAsyncCtx(ctx context.Context, b bool) error {
 	if b {
 		if stored := kdb.checkBackupPragma(PragmaSynchronous); stored != "" && stored != "OFF" {
 			return errors.New("async mode (should be) enabled already")
 		}
-		if err := kdb.backupPragma(PragmaSynchronous); err != nil {
+		if err := kdb.backupPragma(ctx, PragmaSynchronous); err != nil {
 			return err
 		}
-		_, err := kdb.conn.Exec(PragmaSynchronous.SetQuery("OFF"))
-		return err
+		return kdb.exec(ctx, PragmaSynchronous.String(), PragmaSynchronous.SetQuery("OFF"))
 	}
 	var err error
-	if err = kdb.RestorePragma(PragmaSynchronous, "OFF"); err != nil {
-		_, err = kdb.conn.Exec(PragmaSynchronous.SetQuery("NORMAL"))
+	if err = kdb.RestorePragmaCtx(ctx, PragmaSynchronous, "OFF"); err != nil {
+		err = kdb.exec(ctx, PragmaSynchronous.String(), PragmaSynchronous.SetQuery("NORMAL"))
 	}
 	return err
 }
 
 func (kdb *KismetDatabase) JournalSizeLimit(size int64) error {
-	if err := kdb.backupPragma(PragmaJournalSizeLimit); err != nil {
+	return kdb.JournalSizeLimitCtx(context.Background(), size)
+}
+
+// JournalSizeLimitCtx is [KismetDatabase.JournalSizeLimit] with a context.
+func (kdb *KismetDatabase) JournalSizeLimitCtx(ctx context.Context, size int64) error {
+	if err := kdb.backupPragma(ctx, PragmaJournalSizeLimit); err != nil {
 		return err
 	}
-	if _, err := kdb.conn.Exec(PragmaJournalSizeLimit.SetQuery(strconv.Itoa(int(size)))); err != nil {
+	if err := kdb.exec(ctx, PragmaJournalSizeLimit.String(), PragmaJournalSizeLimit.SetQuery(strconv.Itoa(int(size)))); err != nil {
 		return fmt.Errorf("failed to set journal size limit: %w", err)
 	}
 	return nil
 }
 
+// SetTmpDir points SQLite's temp files at path, creating it if needed.
+// A directory created here is removed again by [KismetDatabase.Close].
+// Failures are only logged, see [KismetDatabase.SetTmpDirCtx] to handle them.
 func (kdb *KismetDatabase) SetTmpDir(path string) {
+	if err := kdb.SetTmpDirCtx(context.Background(), path); err != nil {
+		kdb.Logger().Warn("unable to set tmp dir", "dir", path, "err", err)
+	}
+}
+
+// SetTmpDirCtx is [KismetDatabase.SetTmpDir] with a context, returning any failure.
+func (kdb *KismetDatabase) SetTmpDirCtx(ctx context.Context, path string) error {
+	_, statErr := os.Stat(path)
 	_ = os.MkdirAll(path, 0755)
-	_, tmpDirErr := kdb.conn.Exec("PRAGMA temp_store_directory = '" + path + "';")
-	if tmpDirErr != nil {
-		println("WARN: unable to set tmp dir", tmpDirErr.Error())
-		return
+	if err := kdb.exec(ctx, "temp_store_directory", "PRAGMA temp_store_directory = '"+path+"';"); err != nil {
+		return err
 	}
 	kdb.newTmpDir = path
+	kdb.ownTmpDir = errors.Is(statErr, os.ErrNotExist)
+	return nil
 }
@@ -0,0 +1,289 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RepairOptions tunes [KismetDatabase.RepairDevices]. The zero value only reports.
+type RepairOptions struct {
+	// Fix writes the recomputed columns back, rather than only reporting them.
+	Fix bool
+}
+
+// Discrepancy is a summary column of a device that disagrees with the device's blob and records.
+type Discrepancy struct {
+	PhyName  string
+	DevMac   string
+	Column   string
+	Stored   any
+	Computed any
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s %s: %s is %v, should be %v", d.PhyName, d.DevMac, d.Column, d.Stored, d.Computed)
+}
+
+// RepairReport is the outcome of [KismetDatabase.RepairDevices].
+type RepairReport struct {
+	// Devices is the number of devices checked.
+	Devices int
+	// Discrepancies lists every column found to be wrong.
+	Discrepancies []Discrepancy
+	// Fixed is the number of devices whose columns were rewritten.
+	Fixed int
+}
+
+// observed is what the packets and data tables say about a device.
+type observed struct {
+	first, last    sql.NullInt64
+	signal         sql.NullInt64
+	minLat, minLon sql.NullFloat64
+	maxLat, maxLon sql.NullFloat64
+	sumLat, sumLon float64
+	fixes          int64
+}
+
+// merge folds in the observations of another table.
+func (o *observed) merge(other *observed) {
+	minInt := func(a *sql.NullInt64, b sql.NullInt64) {
+		if b.Valid && (!a.Valid || b.Int64 < a.Int64) {
+			*a = b
+		}
+	}
+	maxInt := func(a *sql.NullInt64, b sql.NullInt64) {
+		if b.Valid && (!a.Valid || b.Int64 > a.Int64) {
+			*a = b
+		}
+	}
+	minFloat := func(a *sql.NullFloat64, b sql.NullFloat64) {
+		if b.Valid && (!a.Valid || b.Float64 < a.Float64) {
+			*a = b
+		}
+	}
+	maxFloat := func(a *sql.NullFloat64, b sql.NullFloat64) {
+		if b.Valid && (!a.Valid || b.Float64 > a.Float64) {
+			*a = b
+		}
+	}
+	minInt(&o.first, other.first)
+	maxInt(&o.last, other.last)
+	maxInt(&o.signal, other.signal)
+	minFloat(&o.minLat, other.minLat)
+	minFloat(&o.minLon, other.minLon)
+	maxFloat(&o.maxLat, other.maxLat)
+	maxFloat(&o.maxLon, other.maxLon)
+	o.sumLat += other.sumLat
+	o.sumLon += other.sumLon
+	o.fixes += other.fixes
+}
+
+// widen extends the summary derived from a device's blob by what its records show,
+// as a merge can leave a blob from one log next to the packets of several.
+func (o *observed) widen(ds *deviceSummary) {
+	if o.first.Valid && (ds.FirstTime == 0 || int(o.first.Int64) < ds.FirstTime) {
+		ds.FirstTime = int(o.first.Int64)
+	}
+	if o.last.Valid && int(o.last.Int64) > ds.LastTime {
+		ds.LastTime = int(o.last.Int64)
+	}
+	// signals are negative dBm, with 0 meaning none was seen
+	if o.signal.Valid && (ds.StrongestSignal == 0 || int(o.signal.Int64) > ds.StrongestSignal) {
+		ds.StrongestSignal = int(o.signal.Int64)
+	}
+
+	if o.fixes == 0 {
+		return
+	}
+	// 0,0 is kismet for no location
+	if ds.MinLat == 0 && ds.MinLon == 0 {
+		ds.MinLat, ds.MinLon = o.minLat.Float64, o.minLon.Float64
+	} else {
+		ds.MinLat, ds.MinLon = min(ds.MinLat, o.minLat.Float64), min(ds.MinLon, o.minLon.Float64)
+	}
+	if ds.MaxLat == 0 && ds.MaxLon == 0 {
+		ds.MaxLat, ds.MaxLon = o.maxLat.Float64, o.maxLon.Float64
+	} else {
+		ds.MaxLat, ds.MaxLon = max(ds.MaxLat, o.maxLat.Float64), max(ds.MaxLon, o.maxLon.Float64)
+	}
+	if ds.AvgLat == 0 && ds.AvgLon == 0 {
+		ds.AvgLat, ds.AvgLon = o.sumLat/float64(o.fixes), o.sumLon/float64(o.fixes)
+	}
+}
+
+// observations aggregates the packets sent by, and data records of, every device.
+//
+//goland:noinspection SqlResolve
+func (kdb *KismetDatabase) observations(ctx context.Context) (map[deviceID]*observed, error) {
+	const (
+		lat = "CASE WHEN lat = 0 AND lon = 0 THEN NULL ELSE lat END"
+		lon = "CASE WHEN lat = 0 AND lon = 0 THEN NULL ELSE lon END"
+	)
+	aggregate := func(table, mac, signal string) string {
+		return fmt.Sprintf(
+			"SELECT phyname, %[2]s, min(ts_sec), max(ts_sec), %[3]s, min(%[4]s), min(%[5]s), max(%[4]s), max(%[5]s), "+
+				"ifnull(sum(%[4]s), 0), ifnull(sum(%[5]s), 0), count(%[4]s) FROM %[1]s GROUP BY phyname, %[2]s",
+			table, mac, signal, lat, lon)
+	}
+
+	rows, err := kdb.reader.QueryContext(ctx,
+		aggregate("packets", "sourcemac", "max(nullif(signal, 0))")+" UNION ALL "+aggregate("data", "devmac", "NULL"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate records of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	seen := make(map[deviceID]*observed)
+	for rows.Next() {
+		var (
+			id deviceID
+			o  observed
+		)
+		if err = rows.Scan(&id.phy, &id.mac, &o.first, &o.last, &o.signal, &o.minLat, &o.minLon,
+			&o.maxLat, &o.maxLon, &o.sumLat, &o.sumLon, &o.fixes); err != nil {
+			return nil, fmt.Errorf("failed to scan records: %w", wrapSQLiteError(err))
+		}
+		if prev, ok := seen[id]; ok {
+			prev.merge(&o)
+			continue
+		}
+		seen[id] = &o
+	}
+
+	return seen, wrapSQLiteError(rows.Err())
+}
+
+// repairColumns are the summary columns checked by a repair, and repairIndexes their place in
+// deviceColumns. The phy and MAC identify a device, and are never rewritten.
+var repairColumns, repairIndexes = func() ([]string, []int) {
+	var (
+		cols    []string
+		indexes []int
+	)
+	for i, c := range strings.Split(deviceColumns, ", ") {
+		if c != "phyname" && c != "devmac" && c != "device" {
+			cols = append(cols, c)
+			indexes = append(indexes, i)
+		}
+	}
+	return cols, indexes
+}()
+
+// repairValues returns the values of repairColumns.
+func (ds deviceSummary) repairValues() []any {
+	all := ds.values(nil)
+	vals := make([]any, len(repairIndexes))
+	for i, idx := range repairIndexes {
+		vals[i] = all[idx]
+	}
+	return vals
+}
+
+type deviceRepair struct {
+	rowid   int64
+	summary deviceSummary
+}
+
+// RepairDevices recomputes the summary columns of every device, which queries such as those
+// of [DeviceQuery] rely on, from its blob and from the packets and data records logged for it.
+// Devices whose blob fails to parse are skipped, and reported in the returned error alongside
+// an otherwise complete report.
+//
+//goland:noinspection SqlResolve
+func (kdb *KismetDatabase) RepairDevices(ctx context.Context, opts *RepairOptions) (*RepairReport, error) {
+	if opts == nil {
+		opts = &RepairOptions{}
+	}
+
+	seen, err := kdb.observations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := kdb.reader.QueryContext(ctx,
+		"SELECT rowid, ifnull(phyname, ''), ifnull(devmac, ''), ifnull(first_time, 0), ifnull(last_time, 0), ifnull(devkey, ''), "+
+			"ifnull(strongest_signal, 0), ifnull(min_lat, 0), ifnull(min_lon, 0), ifnull(max_lat, 0), ifnull(max_lon, 0), "+
+			"ifnull(avg_lat, 0), ifnull(avg_lon, 0), ifnull(bytes_data, 0), ifnull(type, ''), device FROM devices ORDER BY rowid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+
+	var (
+		report    = new(RepairReport)
+		repairs   []deviceRepair
+		parseErrs []error
+	)
+
+	// fixes are collected and written once the devices have been read, as a reader
+	// would otherwise hold up the writer on a database that isn't in WAL mode
+	for rows.Next() {
+		var (
+			rowid  int64
+			stored deviceSummary
+			blob   []byte
+		)
+		if err = rows.Scan(&rowid, &stored.PhyName, &stored.DevMac, &stored.FirstTime, &stored.LastTime,
+			&stored.DevKey, &stored.StrongestSignal, &stored.MinLat, &stored.MinLon, &stored.MaxLat, &stored.MaxLon,
+			&stored.AvgLat, &stored.AvgLon, &stored.BytesData, &stored.Type, &blob); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan device: %w", wrapSQLiteError(err))
+		}
+		report.Devices++
+
+		d, parseErr := Parse(blob)
+		if parseErr != nil {
+			parseErrs = append(parseErrs, fmt.Errorf("%s: failed to parse %s %s: %w", kdb.path, stored.PhyName, stored.DevMac, parseErr))
+			continue
+		}
+
+		computed := summarize(d)
+		if o, ok := seen[deviceID{phy: stored.PhyName, mac: stored.DevMac}]; ok {
+			o.widen(&computed)
+		}
+
+		storedVals, computedVals := stored.repairValues(), computed.repairValues()
+		wrong := false
+		for i, col := range repairColumns {
+			if storedVals[i] != computedVals[i] {
+				wrong = true
+				report.Discrepancies = append(report.Discrepancies, Discrepancy{
+					PhyName: stored.PhyName, DevMac: stored.DevMac, Column: col,
+					Stored: storedVals[i], Computed: computedVals[i],
+				})
+			}
+		}
+		if wrong {
+			repairs = append(repairs, deviceRepair{rowid: rowid, summary: computed})
+		}
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read devices of '%s': %w", kdb.path, wrapSQLiteError(err))
+	}
+
+	if opts.Fix && len(repairs) > 0 {
+		query := "UPDATE devices SET " + strings.Join(repairColumns, " = ?, ") + " = ? WHERE rowid = ?"
+		err = kdb.Update(ctx, func(tx *Tx) error {
+			for _, r := range repairs {
+				if _, err := tx.tx.ExecContext(ctx, query, append(r.summary.repairValues(), r.rowid)...); err != nil {
+					return wrapSQLiteError(err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to repair devices: %w", err)
+		}
+		report.Fixed = len(repairs)
+	}
+
+	kdb.Logger().Info("checked device summaries", "devices", report.Devices,
+		"discrepancies", len(report.Discrepancies), "fixed", report.Fixed)
+
+	return report, errors.Join(parseErrs...)
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRepairDevices(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "repair.kismet")
+
+	d, err := Parse([]byte(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.BaseFirstTime, d.BaseLastTime = 100, 200
+	d.BaseSignal.CommonSignalMaxSignal = -60
+	if err = db.InsertDevice(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	// a merged-in log saw the device later, louder and further north
+	//goland:noinspection SqlResolve
+	for _, q := range []string{
+		"INSERT INTO packets (ts_sec, phyname, sourcemac, signal, lat, lon) VALUES (300, 'IEEE802.11', 'AA:AA:AA:AA:AA:01', -30, 53.0, 13.5)",
+		"INSERT INTO packets (ts_sec, phyname, sourcemac, signal, lat, lon) VALUES (150, 'IEEE802.11', 'AA:AA:AA:AA:AA:01', 0, 0, 0)",
+		"INSERT INTO data (ts_sec, phyname, devmac, lat, lon) VALUES (50, 'IEEE802.11', 'AA:AA:AA:AA:AA:01', 52.0, 13.0)",
+		"UPDATE devices SET type = 'Wi-Fi Client'",
+	} {
+		if _, err = db.conn.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := db.RepairDevices(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"first_time": 50, "last_time": 300, "strongest_signal": -30, "type": "Wi-Fi AP",
+		"min_lat": 52.0, "min_lon": 13.0, "max_lat": 53.0, "max_lon": 13.5,
+	}
+	if report.Devices != 1 || report.Fixed != 0 || len(report.Discrepancies) != len(want) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for _, dis := range report.Discrepancies {
+		if want[dis.Column] != dis.Computed {
+			t.Errorf("%s: expected %v, got %v", dis.Column, want[dis.Column], dis)
+		}
+	}
+
+	if report, err = db.RepairDevices(ctx, &RepairOptions{Fix: true}); err != nil || report.Fixed != 1 {
+		t.Fatalf("expected 1 device fixed, got %+v (%v)", report, err)
+	}
+	if report, err = db.RepairDevices(ctx, nil); err != nil || len(report.Discrepancies) != 0 {
+		t.Fatalf("expected no discrepancies after fixing, got %+v (%v)", report, err)
+	}
+
+	insertTestDevice(t, db, "IEEE802.11", "AA:AA:AA:AA:AA:02", "{not json")
+	if report, err = db.RepairDevices(ctx, nil); err == nil || report.Devices != 2 {
+		t.Errorf("expected a parse error alongside the report, got %+v (%v)", report, err)
+	}
+}
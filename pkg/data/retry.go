@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// ErrRetriesExhausted is returned once a [RetryPolicy] gives up on an operation that kept failing.
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+// RetryPolicy decides how write operations that find the database busy are retried.
+type RetryPolicy struct {
+	// MaxAttempts caps the number of attempts, including the first. Zero means no limit.
+	MaxAttempts int
+	// InitialDelay is the wait before the second attempt. Every further wait is
+	// Multiplier times longer than the one before it, up to MaxDelay.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	// Jitter is the fraction, between 0 and 1, of every wait that is randomized
+	// so that competing writers don't retry in lockstep.
+	Jitter float64
+	// Deadline caps the total time spent on an operation, retries included. Zero means no limit.
+	Deadline time.Duration
+	// BusyTimeout is how long SQLite itself waits on a lock before reporting SQLITE_BUSY,
+	// see PRAGMA busy_timeout. It applies to every attempt.
+	BusyTimeout time.Duration
+	// Retryable reports whether an error is worth retrying. It defaults to SQLITE_BUSY and SQLITE_LOCKED.
+	Retryable func(error) bool
+	// Logger receives a warning for every retry. It defaults to the package logger, see [SetLogger].
+	Logger *slog.Logger
+}
+
+// DefaultRetryPolicy is used by every [KismetDatabase] until [KismetDatabase.SetRetryPolicy] is called.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  20,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.5,
+	Deadline:     10 * time.Minute,
+	BusyTimeout:  5 * time.Second,
+}
+
+func (rp RetryPolicy) validate() error {
+	switch {
+	case rp.MaxAttempts < 0:
+		return fmt.Errorf("invalid max attempts: %d", rp.MaxAttempts)
+	case rp.InitialDelay < 0, rp.MaxDelay < 0, rp.Deadline < 0, rp.BusyTimeout < 0:
+		return errors.New("retry policy durations must not be negative")
+	case rp.Multiplier != 0 && rp.Multiplier < 1:
+		return fmt.Errorf("invalid backoff multiplier: %f", rp.Multiplier)
+	case rp.Jitter < 0 || rp.Jitter > 1:
+		return fmt.Errorf("invalid jitter: %f", rp.Jitter)
+	case rp.MaxAttempts == 0 && rp.Deadline == 0:
+		return errors.New("retry policy needs a max attempts limit or a deadline")
+	}
+	return nil
+}
+
+func isBusy(err error) bool {
+	sqe := NewSQLiteError(err)
+	return sqe.IsBusy() || sqe.IsLocked()
+}
+
+func (rp RetryPolicy) retryable(err error) bool {
+	if rp.Retryable != nil {
+		return rp.Retryable(err)
+	}
+	return isBusy(err)
+}
+
+// delay returns the wait after the given (1-based) failed attempt.
+func (rp RetryPolicy) delay(attempt int) time.Duration {
+	multiplier := rp.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+	d := float64(rp.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if rp.MaxDelay > 0 {
+		d = math.Min(d, float64(rp.MaxDelay))
+	}
+	d -= d * rp.Jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+func (rp RetryPolicy) logger() *slog.Logger {
+	if rp.Logger != nil {
+		return rp.Logger
+	}
+	return Logger()
+}
+
+// busyTimeoutQuery returns the PRAGMA that applies the policy's BusyTimeout to a connection.
+func (rp RetryPolicy) busyTimeoutQuery() string {
+	return "PRAGMA busy_timeout = " + strconv.FormatInt(rp.BusyTimeout.Milliseconds(), 10)
+}
+
+// Do runs op until it succeeds, fails with an error the policy doesn't retry, or the policy
+// gives up. what names the operation in progress messages and in the returned error.
+func (rp RetryPolicy) Do(ctx context.Context, what string, op func() error) error {
+	if rp.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rp.Deadline)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !rp.retryable(err) {
+			return err
+		}
+
+		if rp.MaxAttempts > 0 && attempt >= rp.MaxAttempts {
+			return fmt.Errorf("%s: %w after %d attempts over %s: %w",
+				what, ErrRetriesExhausted, attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+
+		d := rp.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+			return fmt.Errorf("%s: %w, deadline reached after %d attempts over %s: %w",
+				what, ErrRetriesExhausted, attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+
+		rp.logger().Warn("database busy, retrying", "op", what, "attempt", attempt, "wait", d.Round(time.Millisecond), "err", err)
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s: %w: %w", what, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// SetRetryPolicy replaces the [RetryPolicy] used by the database's write operations.
+func (kdb *KismetDatabase) SetRetryPolicy(rp RetryPolicy) error {
+	if err := rp.validate(); err != nil {
+		return err
+	}
+	kdb.mu.Lock()
+	kdb.retry = rp
+	kdb.mu.Unlock()
+	return nil
+}
+
+// RetryPolicy returns the [RetryPolicy] used by the database's write operations.
+func (kdb *KismetDatabase) RetryPolicy() RetryPolicy {
+	kdb.mu.Lock()
+	defer kdb.mu.Unlock()
+	return kdb.retry
+}
+
+// exec runs a write statement under the database's [RetryPolicy]. Every attempt gets
+// a connection of its own with the policy's busy timeout applied.
+func (kdb *KismetDatabase) exec(ctx context.Context, what, query string, args ...any) error {
+	rp := kdb.RetryPolicy()
+	if rp.Logger == nil {
+		rp.Logger = kdb.Logger()
+	}
+	return rp.Do(ctx, what, func() error {
+		conn, err := kdb.conn.Conn(ctx)
+		if err != nil {
+			return wrapSQLiteError(err)
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		if _, err = conn.ExecContext(ctx, rp.busyTimeoutQuery()); err != nil {
+			return wrapSQLiteError(err)
+		}
+		_, err = conn.ExecContext(ctx, query, args...)
+		return wrapSQLiteError(err)
+	})
+}
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTestBusy = errors.New("test: busy")
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  4,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   2,
+		Jitter:       0.5,
+		Retryable:    func(err error) bool { return errors.Is(err, errTestBusy) },
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("succeeds after busy", func(t *testing.T) {
+		attempts := 0
+		err := testRetryPolicy().Do(ctx, "test", func() error {
+			if attempts++; attempts < 3 {
+				return errTestBusy
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 3 {
+			t.Errorf("expected 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		attempts := 0
+		err := testRetryPolicy().Do(ctx, "test", func() error {
+			attempts++
+			return errTestBusy
+		})
+		if !errors.Is(err, ErrRetriesExhausted) || !errors.Is(err, errTestBusy) {
+			t.Fatalf("expected exhausted busy error, got %v", err)
+		}
+		if attempts != 4 {
+			t.Errorf("expected 4 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		attempts := 0
+		fatal := errors.New("test: fatal")
+		err := testRetryPolicy().Do(ctx, "test", func() error {
+			attempts++
+			return fatal
+		})
+		if !errors.Is(err, fatal) || errors.Is(err, ErrRetriesExhausted) {
+			t.Fatalf("expected fatal error as is, got %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("expected 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		rp := testRetryPolicy()
+		rp.MaxAttempts = 0
+		rp.InitialDelay = 20 * time.Millisecond
+		rp.MaxDelay = 20 * time.Millisecond
+		rp.Jitter = 0
+		rp.Deadline = 50 * time.Millisecond
+		err := rp.Do(ctx, "test", func() error { return errTestBusy })
+		if !errors.Is(err, ErrRetriesExhausted) {
+			t.Fatalf("expected deadline to exhaust retries, got %v", err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		rp := testRetryPolicy()
+		rp.InitialDelay = time.Hour
+		rp.MaxDelay = time.Hour
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := rp.Do(cctx, "test", func() error { return errTestBusy })
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context canceled, got %v", err)
+		}
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	rp := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := rp.delay(attempt); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+
+	rp.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := rp.delay(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("jittered delay %s outside [100ms, 200ms]", got)
+		}
+	}
+}
+
+func TestSetRetryPolicy(t *testing.T) {
+	db := newTestDatabase(t, "retry.kismet")
+
+	for _, rp := range []RetryPolicy{
+		{MaxAttempts: -1},
+		{MaxAttempts: 1, Jitter: 2},
+		{MaxAttempts: 1, Multiplier: 0.5},
+		{MaxAttempts: 1, BusyTimeout: -time.Second},
+		{},
+	} {
+		if err := db.SetRetryPolicy(rp); err == nil {
+			t.Errorf("expected %+v to be rejected", rp)
+		}
+	}
+
+	if err := db.SetRetryPolicy(testRetryPolicy()); err != nil {
+		t.Fatal(err)
+	}
+	if db.RetryPolicy().MaxAttempts != 4 {
+		t.Error("retry policy was not applied")
+	}
+}
+
+// lockDatabase holds a write lock on db's file from another connection until the returned func is called.
+func lockDatabase(t *testing.T, db *KismetDatabase) func() {
+	t.Helper()
+	other, err := sql.Open("sqlite", db.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := other.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		_ = conn.Close()
+		_ = other.Close()
+	}
+}
+
+func TestRetryBusyDatabase(t *testing.T) {
+	db := newTestDatabase(t, "busy.kismet")
+	if err := db.SetRetryPolicy(RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 10 * time.Millisecond,
+		Multiplier:   1,
+		BusyTimeout:  time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock := lockDatabase(t, db)
+	err := db.ensureProgressTable(context.Background())
+	unlock()
+	if !errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("expected retries to be exhausted while locked, got %v", err)
+	}
+
+	if err = db.SetRetryPolicy(RetryPolicy{
+		MaxAttempts:  50,
+		InitialDelay: 20 * time.Millisecond,
+		Multiplier:   1,
+		BusyTimeout:  time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock = lockDatabase(t, db)
+	time.AfterFunc(100*time.Millisecond, unlock)
+	if err = db.ensureProgressTable(context.Background()); err != nil {
+		t.Fatalf("expected write to succeed once the lock was released, got %v", err)
+	}
+}
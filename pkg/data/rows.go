@@ -0,0 +1,454 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Packet is a row of the packets table.
+type Packet struct {
+	Time       time.Time
+	PhyName    string
+	SourceMac  string
+	DestMac    string
+	TransMac   string
+	Frequency  float64
+	DevKey     string
+	Lat        float64
+	Lon        float64
+	Alt        float64
+	Speed      float64
+	Heading    float64
+	Len        int
+	Signal     int
+	Datasource string
+	DLT        int
+	Packet     []byte
+	Error      int
+	Tags       string
+	DataRate   float64
+	Hash       int64
+	PacketID   int64
+}
+
+// DataRecord is a row of the data table, which holds non-packet records such as those of SDR phys.
+type DataRecord struct {
+	Time       time.Time
+	PhyName    string
+	DevMac     string
+	Lat        float64
+	Lon        float64
+	Alt        float64
+	Speed      float64
+	Heading    float64
+	Datasource string
+	Type       string
+	JSON       map[string]any
+}
+
+// Alert is a row of the alerts table.
+type Alert struct {
+	Time    time.Time
+	PhyName string
+	DevMac  string
+	Lat     float64
+	Lon     float64
+	Header  string
+	JSON    map[string]any
+}
+
+// Message is a row of the messages table. Messages only carry whole seconds.
+type Message struct {
+	Time    time.Time
+	Lat     float64
+	Lon     float64
+	Type    string
+	Message string
+}
+
+// Snapshot is a row of the snapshots table.
+type Snapshot struct {
+	Time time.Time
+	Lat  float64
+	Lon  float64
+	Type string
+	JSON map[string]any
+}
+
+// Datasource is a row of the datasources table.
+type Datasource struct {
+	UUID       string
+	TypeString string
+	Definition string
+	Name       string
+	Interface  string
+	JSON       map[string]any
+}
+
+// RowFilter narrows the rows returned by the table iterators, such as [KismetDatabase.Packets].
+// Zero fields don't filter. Setting a field that the table has no column for is an error.
+type RowFilter struct {
+	// Since and Until select rows logged in [Since, Until).
+	Since time.Time
+	Until time.Time
+	// PhyName selects rows of one phy, such as "IEEE802.11".
+	PhyName string
+	// MAC selects rows where any of the table's MAC columns match.
+	MAC string
+	// Datasource selects rows captured by the datasource with this UUID.
+	Datasource string
+	// Type selects data and snapshot records by type, alerts by header and messages by msgtype.
+	Type string
+	// Limit caps the number of rows returned.
+	Limit int
+}
+
+// rowTable describes which columns of a table the fields of a [RowFilter] apply to.
+type rowTable struct {
+	name       string
+	columns    string
+	timed      bool
+	usec       bool
+	phy        bool
+	macs       []string
+	datasource string
+	kind       string
+}
+
+//goland:noinspection SqlResolve
+var (
+	packetsTable = rowTable{
+		name: "packets",
+		columns: "ifnull(ts_sec, 0), ifnull(ts_usec, 0), ifnull(phyname, ''), ifnull(sourcemac, ''), ifnull(destmac, ''), " +
+			"ifnull(transmac, ''), ifnull(frequency, 0), ifnull(devkey, ''), ifnull(lat, 0), ifnull(lon, 0), " +
+			"ifnull(alt, 0), ifnull(speed, 0), ifnull(heading, 0), ifnull(packet_len, 0), ifnull(signal, 0), " +
+			"ifnull(datasource, ''), ifnull(dlt, 0), packet, ifnull(error, 0), ifnull(tags, ''), " +
+			"ifnull(datarate, 0), ifnull(hash, 0), ifnull(packetid, 0)",
+		timed: true, usec: true, phy: true,
+		macs:       []string{"sourcemac", "destmac", "transmac"},
+		datasource: "datasource",
+	}
+	dataTable = rowTable{
+		name: "data",
+		columns: "ifnull(ts_sec, 0), ifnull(ts_usec, 0), ifnull(phyname, ''), ifnull(devmac, ''), ifnull(lat, 0), " +
+			"ifnull(lon, 0), ifnull(alt, 0), ifnull(speed, 0), ifnull(heading, 0), ifnull(datasource, ''), " +
+			"ifnull(type, ''), json",
+		timed: true, usec: true, phy: true,
+		macs:       []string{"devmac"},
+		datasource: "datasource",
+		kind:       "type",
+	}
+	alertsTable = rowTable{
+		name:    "alerts",
+		columns: "ifnull(ts_sec, 0), ifnull(ts_usec, 0), ifnull(phyname, ''), ifnull(devmac, ''), ifnull(lat, 0), ifnull(lon, 0), ifnull(header, ''), json",
+		timed:   true, usec: true, phy: true,
+		macs: []string{"devmac"},
+		kind: "header",
+	}
+	messagesTable = rowTable{
+		name:    "messages",
+		columns: "ifnull(ts_sec, 0), ifnull(lat, 0), ifnull(lon, 0), ifnull(msgtype, ''), ifnull(message, '')",
+		timed:   true,
+		kind:    "msgtype",
+	}
+	snapshotsTable = rowTable{
+		name:    "snapshots",
+		columns: "ifnull(ts_sec, 0), ifnull(ts_usec, 0), ifnull(lat, 0), ifnull(lon, 0), ifnull(snaptype, ''), json",
+		timed:   true, usec: true,
+		kind: "snaptype",
+	}
+	datasourcesTable = rowTable{
+		name:       "datasources",
+		columns:    "ifnull(uuid, ''), ifnull(typestring, ''), ifnull(definition, ''), ifnull(name, ''), ifnull(interface, ''), json",
+		datasource: "uuid",
+	}
+)
+
+// query builds the SELECT for rt narrowed by f, in rowid order.
+func (rt rowTable) query(f *RowFilter) (string, []any, error) {
+	if f == nil {
+		f = &RowFilter{}
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+
+	unsupported := func(field string) error {
+		return fmt.Errorf("table %s can't be filtered by %s", rt.name, field)
+	}
+
+	// messages only have whole seconds, compare everything else to the microsecond
+	ts := "ifnull(ts_sec, 0) * 1000000"
+	if rt.usec {
+		ts += " + ifnull(ts_usec, 0)"
+	}
+	if !f.Since.IsZero() {
+		if !rt.timed {
+			return "", nil, unsupported("time")
+		}
+		where = append(where, ts+" >= ?")
+		args = append(args, f.Since.UnixMicro())
+	}
+	if !f.Until.IsZero() {
+		if !rt.timed {
+			return "", nil, unsupported("time")
+		}
+		where = append(where, ts+" < ?")
+		args = append(args, f.Until.UnixMicro())
+	}
+
+	if f.PhyName != "" {
+		if !rt.phy {
+			return "", nil, unsupported("phy")
+		}
+		where = append(where, "phyname = ?")
+		args = append(args, f.PhyName)
+	}
+
+	if f.MAC != "" {
+		if len(rt.macs) == 0 {
+			return "", nil, unsupported("mac")
+		}
+		mac, err := normalizeMAC(f.MAC)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, "? IN ("+strings.Join(rt.macs, ", ")+")")
+		args = append(args, mac)
+	}
+
+	if f.Datasource != "" {
+		if rt.datasource == "" {
+			return "", nil, unsupported("datasource")
+		}
+		where = append(where, rt.datasource+" = ?")
+		args = append(args, f.Datasource)
+	}
+
+	if f.Type != "" {
+		if rt.kind == "" {
+			return "", nil, unsupported("type")
+		}
+		where = append(where, rt.kind+" = ?")
+		args = append(args, f.Type)
+	}
+
+	if f.Limit < 0 {
+		return "", nil, fmt.Errorf("invalid limit: %d", f.Limit)
+	}
+
+	query := "SELECT " + rt.columns + " FROM " + rt.name
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY rowid"
+	if f.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(f.Limit)
+	}
+
+	return query, args, nil
+}
+
+// RowIter streams the rows of a table, decoding one at a time. Like [sql.Rows], it must
+// be advanced with Next and closed once done with.
+type RowIter[T any] struct {
+	rows  *sql.Rows
+	scan  func(*sql.Rows) (*T, error)
+	table string
+	row   *T
+	err   error
+}
+
+// Next decodes the next row, reporting false once the rows are exhausted or an error occurs.
+func (ri *RowIter[T]) Next() bool {
+	if ri.err != nil || !ri.rows.Next() {
+		return false
+	}
+	if ri.row, ri.err = ri.scan(ri.rows); ri.err != nil {
+		ri.err = fmt.Errorf("failed to decode row of %s: %w", ri.table, wrapSQLiteError(ri.err))
+		return false
+	}
+	return true
+}
+
+// Row returns the row decoded by the last call to Next.
+func (ri *RowIter[T]) Row() *T {
+	return ri.row
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (ri *RowIter[T]) Err() error {
+	if ri.err != nil {
+		return ri.err
+	}
+	if err := ri.rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", ri.table, wrapSQLiteError(err))
+	}
+	return nil
+}
+
+// Close releases the iterator's connection back to the read pool.
+func (ri *RowIter[T]) Close() error {
+	return wrapSQLiteError(ri.rows.Close())
+}
+
+// All drains the iterator into a slice and closes it.
+func (ri *RowIter[T]) All() ([]*T, error) {
+	defer func() {
+		_ = ri.Close()
+	}()
+	var all []*T
+	for ri.Next() {
+		all = append(all, ri.Row())
+	}
+	return all, ri.Err()
+}
+
+func iterate[T any](ctx context.Context, kdb *KismetDatabase, rt rowTable, f *RowFilter, scan func(*sql.Rows) (*T, error)) (*RowIter[T], error) {
+	query, args, err := rt.query(f)
+	if err != nil {
+		return nil, fmt.Errorf("bad filter for '%s': %w", kdb.path, err)
+	}
+	rows, err := kdb.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s of '%s': %w", rt.name, kdb.path, wrapSQLiteError(err))
+	}
+	return &RowIter[T]{rows: rows, scan: scan, table: rt.name}, nil
+}
+
+func rowTime(sec, usec int64) time.Time {
+	return time.Unix(sec, usec*int64(time.Microsecond))
+}
+
+// decodeJSON decodes a JSON blob column, leaving the result nil for a NULL or empty blob.
+func decodeJSON(b []byte) (map[string]any, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]any)
+	if err := sonic.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Packets iterates over the packets table in the order the packets were logged.
+func (kdb *KismetDatabase) Packets(ctx context.Context, f *RowFilter) (*RowIter[Packet], error) {
+	return iterate(ctx, kdb, packetsTable, f, func(rows *sql.Rows) (*Packet, error) {
+		var (
+			p         = new(Packet)
+			sec, usec int64
+		)
+		err := rows.Scan(&sec, &usec, &p.PhyName, &p.SourceMac, &p.DestMac, &p.TransMac, &p.Frequency,
+			&p.DevKey, &p.Lat, &p.Lon, &p.Alt, &p.Speed, &p.Heading, &p.Len, &p.Signal, &p.Datasource,
+			&p.DLT, &p.Packet, &p.Error, &p.Tags, &p.DataRate, &p.Hash, &p.PacketID)
+		if err != nil {
+			return nil, err
+		}
+		p.Time = rowTime(sec, usec)
+		return p, nil
+	})
+}
+
+// Data iterates over the data table in the order the records were logged.
+func (kdb *KismetDatabase) Data(ctx context.Context, f *RowFilter) (*RowIter[DataRecord], error) {
+	return iterate(ctx, kdb, dataTable, f, func(rows *sql.Rows) (*DataRecord, error) {
+		var (
+			d         = new(DataRecord)
+			sec, usec int64
+			blob      []byte
+		)
+		err := rows.Scan(&sec, &usec, &d.PhyName, &d.DevMac, &d.Lat, &d.Lon, &d.Alt, &d.Speed,
+			&d.Heading, &d.Datasource, &d.Type, &blob)
+		if err != nil {
+			return nil, err
+		}
+		d.Time = rowTime(sec, usec)
+		if d.JSON, err = decodeJSON(blob); err != nil {
+			return nil, err
+		}
+		return d, nil
+	})
+}
+
+// Alerts iterates over the alerts table in the order the alerts were raised.
+func (kdb *KismetDatabase) Alerts(ctx context.Context, f *RowFilter) (*RowIter[Alert], error) {
+	return iterate(ctx, kdb, alertsTable, f, func(rows *sql.Rows) (*Alert, error) {
+		var (
+			a         = new(Alert)
+			sec, usec int64
+			blob      []byte
+		)
+		err := rows.Scan(&sec, &usec, &a.PhyName, &a.DevMac, &a.Lat, &a.Lon, &a.Header, &blob)
+		if err != nil {
+			return nil, err
+		}
+		a.Time = rowTime(sec, usec)
+		if a.JSON, err = decodeJSON(blob); err != nil {
+			return nil, err
+		}
+		return a, nil
+	})
+}
+
+// Messages iterates over the messages table in the order the messages were logged.
+func (kdb *KismetDatabase) Messages(ctx context.Context, f *RowFilter) (*RowIter[Message], error) {
+	return iterate(ctx, kdb, messagesTable, f, func(rows *sql.Rows) (*Message, error) {
+		var (
+			m   = new(Message)
+			sec int64
+		)
+		err := rows.Scan(&sec, &m.Lat, &m.Lon, &m.Type, &m.Message)
+		if err != nil {
+			return nil, err
+		}
+		m.Time = rowTime(sec, 0)
+		return m, nil
+	})
+}
+
+// Snapshots iterates over the snapshots table in the order the snapshots were taken.
+func (kdb *KismetDatabase) Snapshots(ctx context.Context, f *RowFilter) (*RowIter[Snapshot], error) {
+	return iterate(ctx, kdb, snapshotsTable, f, func(rows *sql.Rows) (*Snapshot, error) {
+		var (
+			s         = new(Snapshot)
+			sec, usec int64
+			blob      []byte
+		)
+		err := rows.Scan(&sec, &usec, &s.Lat, &s.Lon, &s.Type, &blob)
+		if err != nil {
+			return nil, err
+		}
+		s.Time = rowTime(sec, usec)
+		if s.JSON, err = decodeJSON(blob); err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// Datasources iterates over the datasources table.
+func (kdb *KismetDatabase) Datasources(ctx context.Context, f *RowFilter) (*RowIter[Datasource], error) {
+	return iterate(ctx, kdb, datasourcesTable, f, func(rows *sql.Rows) (*Datasource, error) {
+		var (
+			ds   = new(Datasource)
+			blob []byte
+		)
+		err := rows.Scan(&ds.UUID, &ds.TypeString, &ds.Definition, &ds.Name, &ds.Interface, &blob)
+		if err != nil {
+			return nil, err
+		}
+		if ds.JSON, err = decodeJSON(blob); err != nil {
+			return nil, err
+		}
+		return ds, nil
+	})
+}
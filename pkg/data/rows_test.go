@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRowIterators(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "rows.kismet")
+	seedTestDatabase(t, db, "00:11:22:33:44:55", 5)
+
+	//goland:noinspection SqlResolve
+	for _, q := range []string{
+		`INSERT INTO data (ts_sec, ts_usec, phyname, devmac, datasource, type, json) VALUES (7, 250, 'RTL433', '00:00:00:00:00:01', 'sdr', 'rtl433', '{"model": "Acurite-Tower"}')`,
+		`INSERT INTO alerts (ts_sec, ts_usec, phyname, devmac, header, json) VALUES (8, 0, 'IEEE802.11', '00:11:22:33:44:55', 'DEAUTHFLOOD', '{"kismet.alert.class": "DENIAL"}')`,
+		`INSERT INTO messages (ts_sec, msgtype, message) VALUES (9, 'INFO', 'hello')`,
+		`INSERT INTO snapshots (ts_sec, ts_usec, snaptype) VALUES (10, 0, 'GPS')`,
+		`INSERT INTO datasources (uuid, typestring, name, json) VALUES ('5FE308BD-0000-0000-0000-000000000000', 'linuxwifi', 'wlan0', '{}')`,
+	} {
+		if _, err := db.conn.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("packets", func(t *testing.T) {
+		iter, err := db.Packets(ctx, &RowFilter{MAC: "00:11:22:33:44:55", Since: time.Unix(1, 0), Until: time.Unix(4, 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets, err := iter.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(packets) != 3 {
+			t.Fatalf("expected packets 1 to 3, got %d", len(packets))
+		}
+		if !packets[0].Time.Equal(time.Unix(1, 0)) || packets[2].PacketID != 3 || packets[0].DestMac != "FF:FF:FF:FF:FF:FF" {
+			t.Errorf("unexpected packet: %+v", packets[0])
+		}
+
+		iter, err = db.Packets(ctx, &RowFilter{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packets, _ = iter.All(); len(packets) != 2 {
+			t.Errorf("expected limit of 2, got %d", len(packets))
+		}
+	})
+
+	t.Run("data", func(t *testing.T) {
+		iter, err := db.Data(ctx, &RowFilter{Type: "rtl433"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := iter.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || records[0].JSON["model"] != "Acurite-Tower" {
+			t.Fatalf("unexpected records: %+v", records)
+		}
+		if want := time.Unix(7, 250*int64(time.Microsecond)); !records[0].Time.Equal(want) {
+			t.Errorf("expected %s, got %s", want, records[0].Time)
+		}
+	})
+
+	t.Run("others", func(t *testing.T) {
+		alerts, err := db.Alerts(ctx, &RowFilter{PhyName: "IEEE802.11"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if all, _ := alerts.All(); len(all) != 1 || all[0].JSON["kismet.alert.class"] != "DENIAL" {
+			t.Errorf("unexpected alerts: %+v", all)
+		}
+
+		messages, err := db.Messages(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if all, _ := messages.All(); len(all) != 1 || all[0].Message != "hello" {
+			t.Errorf("unexpected messages: %+v", all)
+		}
+
+		snapshots, err := db.Snapshots(ctx, &RowFilter{Type: "GPS"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if all, _ := snapshots.All(); len(all) != 1 || all[0].JSON != nil {
+			t.Errorf("unexpected snapshots: %+v", all)
+		}
+
+		sources, err := db.Datasources(ctx, &RowFilter{Datasource: "5FE308BD-0000-0000-0000-000000000000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if all, _ := sources.All(); len(all) != 1 || all[0].Name != "wlan0" {
+			t.Errorf("unexpected datasources: %+v", all)
+		}
+	})
+
+	t.Run("bad filters", func(t *testing.T) {
+		if _, err := db.Messages(ctx, &RowFilter{MAC: "00:11:22:33:44:55"}); err == nil {
+			t.Error("expected messages to reject a mac filter")
+		}
+		if _, err := db.Datasources(ctx, &RowFilter{Since: time.Unix(1, 0)}); err == nil {
+			t.Error("expected datasources to reject a time filter")
+		}
+		if _, err := db.Packets(ctx, &RowFilter{MAC: "not a mac"}); err == nil {
+			t.Error("expected a bad mac to be rejected")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Sample is the value of an RRD for the period starting at Time.
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Series is one ring buffer of an RRD in time order, oldest sample first. Slots holding the
+// RRD's blank value, which Kismet fills the periods it recorded nothing for with, are left out:
+// a period without a sample saw no signal or, for counters whose blank value is 0, no packets.
+type Series struct {
+	// Name is the RRD and its resolution, such as "packets.hour".
+	Name string `json:"name"`
+	// Step is the period of each sample, in seconds.
+	Step    int      `json:"step"`
+	Samples []Sample `json:"samples"`
+}
+
+// Resolutions of the ring buffers of an RRD. Kismet keeps a value in slot
+// (time / step) % len, blanking the slots it skips when the RRD isn't updated.
+const (
+	rrdSecond = 1
+	rrdMinute = 60
+	rrdHour   = 60 * 60
+)
+
+// decodeRRD unrolls the ring buffer vec, last updated at last, into a series, skipping blank slots.
+func decodeRRD(name string, last, step int, blank float64, vec []float64) Series {
+	s := Series{Name: name, Step: step, Samples: make([]Sample, 0, len(vec))}
+	if last <= 0 || len(vec) == 0 {
+		return s
+	}
+
+	n := int64(len(vec))
+	newest := int64(last / step)
+	for slot := newest - n + 1; slot <= newest; slot++ {
+		if slot < 0 || vec[slot%n] == blank {
+			continue
+		}
+		s.Samples = append(s.Samples, Sample{Time: time.Unix(slot*int64(step), 0), Value: vec[slot%n]})
+	}
+	return s
+}
+
+func floats(vec []int) []float64 {
+	fs := make([]float64, len(vec))
+	for i, v := range vec {
+		fs[i] = float64(v)
+	}
+	return fs
+}
+
+// Series decodes the RRD into its last minute, hour and day, named after name.
+func (r *RRD) Series(name string) []Series {
+	blank := float64(r.CommonRrdBlankVal)
+	return []Series{
+		decodeRRD(name+".minute", r.CommonRrdLastTime, rrdSecond, blank, floats(r.CommonRrdMinuteVec)),
+		decodeRRD(name+".hour", r.CommonRrdLastTime, rrdMinute, blank, r.CommonRrdHourVec),
+		decodeRRD(name+".day", r.CommonRrdLastTime, rrdHour, blank, r.CommonRrdDayVec),
+	}
+}
+
+// Series decodes the RRD into its last minute, named after name.
+func (r *MinuteRRD) Series(name string) []Series {
+	return []Series{decodeRRD(name+".minute", r.CommonRrdLastTime, rrdSecond, float64(r.CommonRrdBlankVal), floats(r.CommonRrdMinuteVec))}
+}
+
+// DeviceSeries are the RRDs of a device, decoded.
+type DeviceSeries struct {
+	PhyName string `json:"phyname"`
+	MAC     string `json:"mac"`
+	// Vendor and Randomized are the enrichment of the device, see [Device.Enrich].
+	Vendor     string   `json:"vendor,omitempty"`
+	Randomized bool     `json:"randomized,omitempty"`
+	Series     []Series `json:"series"`
+}
+
+// Series decodes the signal, packets, tx packets and data size RRDs of the device.
+func (d *Device) Series() *DeviceSeries {
+	ds := &DeviceSeries{PhyName: d.BasePhyname, MAC: d.BaseMacaddr, Vendor: d.Vendor, Randomized: d.RandomizedMAC}
+	ds.Series = append(ds.Series, d.BaseSignal.CommonSignalSignalRrd.Series("signal")...)
+	ds.Series = append(ds.Series, d.BasePacketsRrd.Series("packets")...)
+	ds.Series = append(ds.Series, d.BaseTxPacketsRrd.Series("tx_packets")...)
+	ds.Series = append(ds.Series, d.BaseDatasizeRrd.Series("datasize")...)
+	return ds
+}
+
+// seriesCSVHeader are the columns written by [WriteSeriesCSV].
+var seriesCSVHeader = []string{"phyname", "mac", "series", "step", "time", "value"}
+
+// WriteSeriesCSV writes the series of devices as CSV, one row per sample.
+func WriteSeriesCSV(w io.Writer, devices ...*DeviceSeries) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(seriesCSVHeader); err != nil {
+		return err
+	}
+	for _, ds := range devices {
+		for _, s := range ds.Series {
+			step := strconv.Itoa(s.Step)
+			for _, sample := range s.Samples {
+				err := cw.Write([]string{
+					ds.PhyName, ds.MAC, s.Name, step,
+					sample.Time.UTC().Format(time.RFC3339),
+					strconv.FormatFloat(sample.Value, 'f', -1, 64),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSeriesJSON writes the series of devices as an indented JSON array.
+func WriteSeriesJSON(w io.Writer, devices ...*DeviceSeries) error {
+	if devices == nil {
+		devices = make([]*DeviceSeries, 0)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(devices)
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRRDSeries(t *testing.T) {
+	// updated 30 seconds into minute 10 of hour 5, with the value of the current slot being
+	// the slot number, so that the newest sample of each series is of the current slot, and
+	// slot 0 not being blank
+	last := 5*3600 + 10*60 + 30
+	rrd := RRD{CommonRrdLastTime: last, CommonRrdBlankVal: -1}
+	for i := 0; i < 60; i++ {
+		rrd.CommonRrdMinuteVec = append(rrd.CommonRrdMinuteVec, i)
+		rrd.CommonRrdHourVec = append(rrd.CommonRrdHourVec, float64(i))
+	}
+	for i := 0; i < 24; i++ {
+		rrd.CommonRrdDayVec = append(rrd.CommonRrdDayVec, float64(i))
+	}
+
+	series := rrd.Series("packets")
+	if len(series) != 3 {
+		t.Fatalf("expected 3 series, got %d", len(series))
+	}
+	for _, tc := range []struct {
+		name      string
+		n         int
+		step      int
+		oldest    time.Time
+		newestVal float64
+	}{
+		{"packets.minute", 60, 1, time.Unix(int64(last-59), 0), 30},
+		{"packets.hour", 60, 60, time.Unix(int64(4*3600+11*60), 0), 10},
+		// the day reaches back before the epoch, whose slots are left out
+		{"packets.day", 6, 3600, time.Unix(0, 0), 5},
+	} {
+		var s Series
+		for _, s = range series {
+			if s.Name == tc.name {
+				break
+			}
+		}
+		if s.Name != tc.name || len(s.Samples) != tc.n || s.Step != tc.step {
+			t.Errorf("unexpected %s: %d samples of %d seconds", s.Name, len(s.Samples), s.Step)
+			continue
+		}
+		if oldest := s.Samples[0].Time; !oldest.Equal(tc.oldest) {
+			t.Errorf("%s: expected the oldest sample at %s, got %s", tc.name, tc.oldest, oldest)
+		}
+		if newest := s.Samples[len(s.Samples)-1].Value; newest != tc.newestVal {
+			t.Errorf("%s: expected the newest sample to be %v, got %v", tc.name, tc.newestVal, newest)
+		}
+	}
+
+	if s := (&MinuteRRD{}).Series("signal"); len(s) != 1 || len(s[0].Samples) != 0 {
+		t.Errorf("expected an empty series for a RRD never updated: %+v", s)
+	}
+}
+
+func TestRRDSeriesBlank(t *testing.T) {
+	// a signal seen 0 and 2 seconds into the minute, the second between and the rest blank
+	vec := make([]int, 60)
+	for i := range vec {
+		vec[i] = -256
+	}
+	vec[0], vec[2] = -40, -42
+	rrd := MinuteRRD{CommonRrdLastTime: 1699999982, CommonRrdMinuteVec: vec, CommonRrdBlankVal: -256}
+
+	s := rrd.Series("signal")[0]
+	if len(s.Samples) != 2 {
+		t.Fatalf("expected the blank slots to be left out, got %+v", s.Samples)
+	}
+	for i, want := range []Sample{{time.Unix(1699999980, 0), -40}, {time.Unix(1699999982, 0), -42}} {
+		if !s.Samples[i].Time.Equal(want.Time) || s.Samples[i].Value != want.Value {
+			t.Errorf("sample %d: expected %+v, got %+v", i, want, s.Samples[i])
+		}
+	}
+}
+
+func TestWriteSeries(t *testing.T) {
+	d, err := Parse([]byte(`{"kismet.device.base.phyname": "IEEE802.11", "kismet.device.base.macaddr": "AA:AA:AA:AA:AA:01",
+		"kismet.device.base.signal": {"kismet.common.signal.signal_rrd": {"kismet.common.rrd.last_time": 1700000001,
+			"kismet.common.rrd.minute_vec": [-40, -41]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := d.Series()
+
+	var b bytes.Buffer
+	if err = WriteSeriesCSV(&b, ds); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected a header and 2 samples, got %v", rows)
+	}
+	// 1700000001 is slot 1, the oldest sample slot 0
+	if want := []string{"IEEE802.11", "AA:AA:AA:AA:AA:01", "signal.minute", "1", "2023-11-14T22:13:20Z", "-40"}; !slices.Equal(rows[1], want) {
+		t.Errorf("expected %v, got %v", want, rows[1])
+	}
+
+	b.Reset()
+	if err = WriteSeriesJSON(&b, ds); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []DeviceSeries
+	if err = json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded[0].Series[0].Samples[1].Value != -41 {
+		t.Errorf("unexpected json: %s", b.String())
+	}
+}
@@ -0,0 +1,274 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// RTL433 is the rtl433.device record of a sensor received by an rtl_433 datasource.
+type RTL433 struct {
+	Model   string `json:"rtl433.device.model"`
+	ID      string `json:"rtl433.device.id"`
+	Channel string `json:"rtl433.device.channel"`
+	Battery string `json:"rtl433.device.battery"`
+	// Thermometer, Weather and TPMS are only logged for sensors that report them.
+	Thermometer *RTL433Thermometer `json:"rtl433.device.thermometer"`
+	Weather     *RTL433Weather     `json:"rtl433.device.weatherstation"`
+	TPMS        *RTL433TPMS        `json:"rtl433.device.tpms"`
+
+	// Unknown holds the keys of the rtl433 record that RTL433 doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+// RTL433Thermometer is the last reading of a temperature and humidity sensor.
+type RTL433Thermometer struct {
+	// Temperature is in degrees Celsius.
+	Temperature float64 `json:"rtl433.device.temp"`
+	// Humidity is relative, in percent.
+	Humidity int `json:"rtl433.device.humidity"`
+}
+
+// RTL433Weather is the last reading of a weather station.
+type RTL433Weather struct {
+	// WindDir is in degrees, WindSpeed and WindGust in km/h.
+	WindDir   int     `json:"rtl433.device.weatherstation.wind_dir"`
+	WindSpeed float64 `json:"rtl433.device.weatherstation.wind_speed"`
+	WindGust  float64 `json:"rtl433.device.weatherstation.wind_gust"`
+	// Rain is the rain counter of the station, in mm.
+	Rain float64 `json:"rtl433.device.weatherstation.rain"`
+	UV   int     `json:"rtl433.device.weatherstation.uv"`
+	Lux  int     `json:"rtl433.device.weatherstation.lux"`
+}
+
+// RTL433TPMS is the last reading of a tyre pressure sensor.
+type RTL433TPMS struct {
+	Pressure    float64 `json:"rtl433.device.tpms.pressure_kpa"`
+	Temperature float64 `json:"rtl433.device.tpms.temp"`
+	Flags       string  `json:"rtl433.device.tpms.flags"`
+}
+
+type rtl433 RTL433
+
+func (r *RTL433) UnmarshalJSON(b []byte) (err error) {
+	r.Unknown, r.known, err = decodeLossless(b, (*rtl433)(r))
+	return err
+}
+
+func (r RTL433) MarshalJSON() ([]byte, error) {
+	return encodeLossless(rtl433(r), r.Unknown, r.known)
+}
+
+// ADSB is the adsb.device record of an aircraft received by an ADS-B datasource.
+type ADSB struct {
+	ICAO     string `json:"adsb.device.icao"`
+	Callsign string `json:"adsb.device.callsign"`
+	// Altitude is in feet, Speed in knots and Heading in degrees.
+	Altitude  float64 `json:"adsb.device.altitude"`
+	Speed     float64 `json:"adsb.device.speed"`
+	Heading   float64 `json:"adsb.device.heading"`
+	Latitude  float64 `json:"adsb.device.latitude"`
+	Longitude float64 `json:"adsb.device.longitude"`
+
+	// Unknown holds the keys of the adsb record that ADSB doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type adsb ADSB
+
+func (a *ADSB) UnmarshalJSON(b []byte) (err error) {
+	a.Unknown, a.known, err = decodeLossless(b, (*adsb)(a))
+	return err
+}
+
+func (a ADSB) MarshalJSON() ([]byte, error) {
+	return encodeLossless(adsb(a), a.Unknown, a.known)
+}
+
+// AMR is the rtlamr.device record of a utility meter received by an rtlamr datasource.
+type AMR struct {
+	MeterID   int64  `json:"rtlamr.device.meter_id"`
+	MeterType string `json:"rtlamr.device.meter_type"`
+	// Consumption is the last reading, in the meter's own unit.
+	Consumption float64 `json:"rtlamr.device.consumption"`
+
+	// Unknown holds the keys of the rtlamr record that AMR doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type amr AMR
+
+func (a *AMR) UnmarshalJSON(b []byte) (err error) {
+	a.Unknown, a.known, err = decodeLossless(b, (*amr)(a))
+	return err
+}
+
+func (a AMR) MarshalJSON() ([]byte, error) {
+	return encodeLossless(amr(a), a.Unknown, a.known)
+}
+
+// UAV is the uav.device record of a drone, identified by its DroneID or Remote ID broadcasts
+// or by matching its manufacturer and SSID.
+type UAV struct {
+	Manufacturer string `json:"uav.manufacturer"`
+	Model        string `json:"uav.model"`
+	SerialNumber string `json:"uav.serialnumber"`
+	// MatchType is how the device was recognised as a UAV, such as "droneid".
+	MatchType        string         `json:"uav.match_type"`
+	HomeLocation     Location       `json:"uav.home_location"`
+	LastTelemetry    UAVTelemetry   `json:"uav.last_telemetry"`
+	TelemetryHistory []UAVTelemetry `json:"uav.telemetry_history"`
+
+	// Unknown holds the keys of the uav record that UAV doesn't model, as logged.
+	Unknown map[string]json.RawMessage `json:"-"`
+	known   map[string]json.RawMessage
+}
+
+type uav UAV
+
+func (u *UAV) UnmarshalJSON(b []byte) (err error) {
+	u.Unknown, u.known, err = decodeLossless(b, (*uav)(u))
+	return err
+}
+
+func (u UAV) MarshalJSON() ([]byte, error) {
+	return encodeLossless(uav(u), u.Unknown, u.known)
+}
+
+// UAVTelemetry is one telemetry report of a drone, as kept in its uav.device record and
+// logged to the data table.
+type UAVTelemetry struct {
+	Location Location `json:"uav.telemetry.location"`
+	// OperatorLocation is where the drone's remote was, as broadcast by DroneID.
+	OperatorLocation Location `json:"uav.telemetry.app_location"`
+	// Height is above the take-off point, in meters.
+	Height float64 `json:"uav.telemetry.height"`
+	Yaw    float64 `json:"uav.telemetry.yaw"`
+	Pitch  float64 `json:"uav.telemetry.pitch"`
+	Roll   float64 `json:"uav.telemetry.roll"`
+	// VNorth, VEast and VUp are the velocity, in m/s.
+	VNorth   float64 `json:"uav.telemetry.v_north"`
+	VEast    float64 `json:"uav.telemetry.v_east"`
+	VUp      float64 `json:"uav.telemetry.v_up"`
+	MotorOn  int     `json:"uav.telemetry.motor_on"`
+	Airborne int     `json:"uav.telemetry.airborne"`
+	// Time is the time of the report, in seconds since the epoch.
+	Time float64 `json:"uav.telemetry.time"`
+}
+
+// Timestamp returns Time as a time.Time.
+func (ut *UAVTelemetry) Timestamp() time.Time {
+	sec, frac := math.Modf(ut.Time)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+// TrackPoint is a position at a point in time.
+type TrackPoint struct {
+	Time time.Time `json:"time"`
+	Lat  float64   `json:"lat"`
+	Lon  float64   `json:"lon"`
+	Alt  float64   `json:"alt"`
+}
+
+// UAVTrack is where a drone and its operator were, in time order.
+type UAVTrack struct {
+	MAC      string       `json:"mac"`
+	Drone    []TrackPoint `json:"drone"`
+	Operator []TrackPoint `json:"operator"`
+}
+
+func trackPoint(t time.Time, l Location) (TrackPoint, bool) {
+	// 0,0 is kismet for no location
+	if l.Lat() == 0 && l.Lon() == 0 {
+		return TrackPoint{}, false
+	}
+	return TrackPoint{Time: t, Lat: l.Lat(), Lon: l.Lon(), Alt: l.Alt}, true
+}
+
+// Decode decodes the record's JSON into v, such as a [UAVTelemetry].
+func (dr *DataRecord) Decode(v any) error {
+	b, err := sonic.Marshal(dr.JSON)
+	if err != nil {
+		return err
+	}
+	return sonic.Unmarshal(b, v)
+}
+
+// UAVTracks extracts the positions of drones and their operators from the telemetry that the
+// data table holds for devices of [PhyUAV], narrowed by f. Tracks are ordered by MAC.
+func (kdb *KismetDatabase) UAVTracks(ctx context.Context, f *RowFilter) ([]*UAVTrack, error) {
+	uf := RowFilter{}
+	if f != nil {
+		uf = *f
+	}
+	if uf.PhyName != "" && uf.PhyName != PhyUAV {
+		return nil, fmt.Errorf("bad filter for '%s': tracks are of phy %s, not %s", kdb.path, PhyUAV, uf.PhyName)
+	}
+	uf.PhyName = PhyUAV
+
+	iter, err := kdb.Data(ctx, &uf)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	tracks := make(map[string]*UAVTrack)
+	for iter.Next() {
+		rec := iter.Row()
+		var ut UAVTelemetry
+		if err = rec.Decode(&ut); err != nil {
+			kdb.Logger().Debug("skipping data record that isn't uav telemetry", "mac", rec.DevMac, "err", err)
+			continue
+		}
+
+		// records without a time of their own were logged when received
+		ts := rec.Time
+		if ut.Time != 0 {
+			ts = ut.Timestamp()
+		}
+
+		track, ok := tracks[rec.DevMac]
+		if !ok {
+			track = &UAVTrack{MAC: rec.DevMac}
+			tracks[rec.DevMac] = track
+		}
+		if p, ok := trackPoint(ts, ut.Location); ok {
+			track.Drone = append(track.Drone, p)
+		}
+		if p, ok := trackPoint(ts, ut.OperatorLocation); ok {
+			track.Operator = append(track.Operator, p)
+		}
+	}
+	if err = iter.Err(); err != nil {
+		return nil, err
+	}
+
+	byTime := func(a, b TrackPoint) int {
+		return a.Time.Compare(b.Time)
+	}
+	all := make([]*UAVTrack, 0, len(tracks))
+	for _, track := range tracks {
+		if len(track.Drone) == 0 && len(track.Operator) == 0 {
+			continue
+		}
+		slices.SortStableFunc(track.Drone, byTime)
+		slices.SortStableFunc(track.Operator, byTime)
+		all = append(all, track)
+	}
+	slices.SortFunc(all, func(a, b *UAVTrack) int {
+		return strings.Compare(a.MAC, b.MAC)
+	})
+
+	return all, nil
+}
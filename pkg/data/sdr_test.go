@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseSDR(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		blob  string
+		check func(d *Device) bool
+	}{
+		{
+			"rtl433",
+			`{"kismet.device.base.phyname": "RTL433", "rtl433.device": {"rtl433.device.model": "Acurite-Tower", "rtl433.device.id": "1234",
+				"rtl433.device.thermometer": {"rtl433.device.temp": 21.5, "rtl433.device.humidity": 40}, "rtl433.device.rssi": -3.2}}`,
+			func(d *Device) bool {
+				return d.RTL433 != nil && d.RTL433.Model == "Acurite-Tower" && d.RTL433.Thermometer.Temperature == 21.5 &&
+					d.RTL433.Weather == nil && d.RTL433.Unknown["rtl433.device.rssi"] != nil
+			},
+		},
+		{
+			"adsb",
+			`{"kismet.device.base.phyname": "ADSB", "adsb.device": {"adsb.device.icao": "3C6444", "adsb.device.callsign": "DLH4AB", "adsb.device.altitude": 36000}}`,
+			func(d *Device) bool {
+				return d.ADSB != nil && d.ADSB.Callsign == "DLH4AB" && d.ADSB.Altitude == 36000
+			},
+		},
+		{
+			"amr",
+			`{"kismet.device.base.phyname": "RTLAMR", "rtlamr.device": {"rtlamr.device.meter_id": 48291734, "rtlamr.device.consumption": 1042.5}}`,
+			func(d *Device) bool {
+				return d.AMR != nil && d.AMR.MeterID == 48291734 && d.AMR.Consumption == 1042.5
+			},
+		},
+		{
+			"uav",
+			`{"kismet.device.base.phyname": "UAV", "uav.device": {"uav.manufacturer": "DJI", "uav.match_type": "droneid",
+				"uav.last_telemetry": {"uav.telemetry.location": {"kismet.common.location.geopoint": [13.4, 52.5]}, "uav.telemetry.height": 80}}}`,
+			func(d *Device) bool {
+				return d.UAV != nil && d.UAV.Manufacturer == "DJI" && d.UAV.LastTelemetry.Location.Lat() == 52.5 &&
+					d.RTL433 == nil
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := Parse([]byte(tc.blob))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.check(d) {
+				t.Fatalf("unexpected device: %+v", d)
+			}
+			b, err := Encode(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equivalentJSON(t, b, []byte(tc.blob)) {
+				t.Errorf("round trip lost data:\n%s", b)
+			}
+		})
+	}
+}
+
+func TestUAVTracks(t *testing.T) {
+	db := newTestDatabase(t, "uav.kismet")
+
+	//goland:noinspection SqlResolve
+	for _, q := range []string{
+		`INSERT INTO data (ts_sec, phyname, devmac, type, json) VALUES (20, 'UAV', '60:60:1F:00:00:01', 'uav', '{
+			"uav.telemetry.location": {"kismet.common.location.geopoint": [13.41, 52.51], "kismet.common.location.alt": 120},
+			"uav.telemetry.app_location": {"kismet.common.location.geopoint": [13.4, 52.5]}, "uav.telemetry.time": 1700000010.5}')`,
+		`INSERT INTO data (ts_sec, phyname, devmac, type, json) VALUES (10, 'UAV', '60:60:1F:00:00:01', 'uav', '{
+			"uav.telemetry.location": {"kismet.common.location.geopoint": [13.4, 52.5]}, "uav.telemetry.time": 1700000000}')`,
+		`INSERT INTO data (ts_sec, phyname, devmac, type, json) VALUES (30, 'UAV', '60:60:1F:00:00:02', 'uav', '{
+			"uav.telemetry.location": {"kismet.common.location.geopoint": [0, 0]}}')`,
+		`INSERT INTO data (ts_sec, phyname, devmac, type, json) VALUES (40, 'RTL433', '00:00:00:00:00:01', 'rtl433', '{"model": "Acurite-Tower"}')`,
+	} {
+		if _, err := db.conn.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tracks, err := db.UAVTracks(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 {
+		t.Fatalf("expected the drone without a position to be left out, got %d tracks", len(tracks))
+	}
+	track := tracks[0]
+	if len(track.Drone) != 2 || len(track.Operator) != 1 {
+		t.Fatalf("unexpected track: %+v", track)
+	}
+	if !track.Drone[0].Time.Equal(time.Unix(1700000000, 0)) || track.Drone[1].Alt != 120 || track.Drone[1].Lat != 52.51 {
+		t.Errorf("expected the drone's positions in time order: %+v", track.Drone)
+	}
+	if want := time.Unix(1700000010, int64(time.Second/2)); !track.Operator[0].Time.Equal(want) {
+		t.Errorf("expected the operator at %s, got %s", want, track.Operator[0].Time)
+	}
+
+	if _, err = db.UAVTracks(context.Background(), &RowFilter{PhyName: "RTL433"}); err == nil {
+		t.Error("expected a filter for another phy to be rejected")
+	}
+}
@@ -0,0 +1,158 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// ErrInsufficientSpace is returned when a merge preflight finds a filesystem without enough free space.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// spaceHeadroom is added on top of every estimate to absorb indexes, page slack and the like.
+const spaceHeadroom = 0.1
+
+// SpaceRequirement is the estimated disk usage of a merge on a single filesystem.
+type SpaceRequirement struct {
+	// Paths are the locations involved in the merge that live on this filesystem.
+	Paths []string
+	Need  int64
+	Free  int64
+}
+
+func (sr *SpaceRequirement) String() string {
+	return fmt.Sprintf("%s: need %s, have %s free", strings.Join(sr.Paths, ", "),
+		humanize.IBytes(uint64(sr.Need)), humanize.IBytes(uint64(sr.Free)))
+}
+
+// Sufficient reports whether the filesystem has enough free space for the estimate.
+func (sr *SpaceRequirement) Sufficient() bool {
+	return sr.Free >= sr.Need
+}
+
+// TmpDir returns the directory SQLite spills temporary files (such as VACUUM's copy) into.
+func (kdb *KismetDatabase) TmpDir() string {
+	if kdb.newTmpDir != "" {
+		return kdb.newTmpDir
+	}
+	if td := os.Getenv("SQLITE_TMPDIR"); td != "" {
+		return td
+	}
+	return os.TempDir()
+}
+
+// existingDir walks up from path until it finds a directory that exists,
+// so that space can be checked for files and directories not yet created.
+func existingDir(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		dir = path
+	}
+	for {
+		if stat, statErr := os.Stat(dir); statErr == nil && stat.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// EstimateMergeSpace estimates how much disk space merging sources into kdb with opts
+// will need on each filesystem involved, and how much is currently free on each.
+//
+// The target grows by the combined size of the sources, and its write-ahead log by up to
+// the size of the largest source. A full VACUUM needs roughly twice the final size: one
+// copy in the temp directory and one in the target's journal. [TidyVacuumInto] instead
+// needs the final size at its destination.
+func (kdb *KismetDatabase) EstimateMergeSpace(opts *MergeOptions, sources ...string) ([]*SpaceRequirement, error) {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+
+	targetSize, err := kdb.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	var sourcesSize, largest int64
+	for _, source := range sources {
+		size, sizeErr := fileSize(source)
+		if sizeErr != nil {
+			return nil, sizeErr
+		}
+		sourcesSize += size
+		largest = max(largest, size)
+	}
+
+	finalSize := targetSize + sourcesSize
+
+	var (
+		needs = map[string]int64{kdb.path: sourcesSize + largest}
+		order = []string{kdb.path}
+	)
+
+	addNeed := func(path string, n int64) {
+		if _, ok := needs[path]; !ok {
+			order = append(order, path)
+		}
+		needs[path] += n
+	}
+
+	switch opts.Tidy {
+	case TidyAtEnd, TidyEveryGroup:
+		addNeed(kdb.TmpDir(), finalSize)
+		addNeed(kdb.path, finalSize)
+	case TidyIncremental:
+		// only the initial switch to auto_vacuum=INCREMENTAL rewrites the file
+		addNeed(kdb.TmpDir(), targetSize)
+		addNeed(kdb.path, targetSize)
+	case TidyVacuumInto:
+		addNeed(opts.VacuumInto, finalSize)
+	}
+
+	var (
+		reqs = make([]*SpaceRequirement, 0, len(order))
+		byFS = make(map[uint64]*SpaceRequirement)
+	)
+
+	for _, path := range order {
+		fsID, free, fsErr := diskFree(existingDir(path))
+		if fsErr != nil {
+			return nil, fsErr
+		}
+		need := needs[path] + int64(float64(needs[path])*spaceHeadroom)
+		if req, ok := byFS[fsID]; ok {
+			req.Paths = append(req.Paths, path)
+			req.Need += need
+			continue
+		}
+		req := &SpaceRequirement{Paths: []string{path}, Need: need, Free: free}
+		byFS[fsID] = req
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
+// CheckMergeSpace runs [KismetDatabase.EstimateMergeSpace] and returns an error
+// wrapping [ErrInsufficientSpace] for every filesystem that would fill up.
+func (kdb *KismetDatabase) CheckMergeSpace(opts *MergeOptions, sources ...string) error {
+	reqs, err := kdb.EstimateMergeSpace(opts, sources...)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, req := range reqs {
+		if !req.Sufficient() {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrInsufficientSpace, req))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,40 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEstimateMergeSpace(t *testing.T) {
+	target := newTestDatabase(t, "target.kismet")
+	source := newTestDatabase(t, "source.kismet")
+	seedTestDatabase(t, source, "00:11:22:33:44:55", 100)
+
+	sourceSize, err := source.Size()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, opts := range []*MergeOptions{nil, {Tidy: TidyNever}, {Tidy: TidyVacuumInto, VacuumInto: t.TempDir() + "/into.kismet"}} {
+		reqs, estErr := target.EstimateMergeSpace(opts, source.String())
+		if errors.Is(estErr, errors.ErrUnsupported) {
+			t.Skip("disk space checks unsupported on this platform")
+		}
+		if estErr != nil {
+			t.Fatal(estErr.Error())
+		}
+		idx := slices.IndexFunc(reqs, func(req *SpaceRequirement) bool {
+			return slices.Contains(req.Paths, target.String())
+		})
+		if idx < 0 {
+			t.Fatalf("target missing from estimate: %v", reqs)
+		}
+		if reqs[idx].Need < sourceSize {
+			t.Errorf("expected to need at least %d bytes, got %s", sourceSize, reqs[idx])
+		}
+		if reqs[idx].Free <= 0 {
+			t.Errorf("expected free space to be reported, got %s", reqs[idx])
+		}
+	}
+}
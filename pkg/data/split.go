@@ -0,0 +1,461 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SplitMode determines how [SplitKismetDatabase] partitions a database.
+type SplitMode uint8
+
+const (
+	// SplitByTime partitions rows into UTC-aligned windows of [SplitOptions.Window] by their ts_sec.
+	SplitByTime SplitMode = iota
+	// SplitByDatasource partitions rows by the uuid of the datasource that captured them.
+	SplitByDatasource
+	// SplitByServer partitions rows by the kismet.server.uuid of the device they belong to.
+	SplitByServer
+	// SplitByPhy partitions rows by phyname.
+	SplitByPhy
+)
+
+var splitModeNames = map[SplitMode]string{
+	SplitByTime:       "time",
+	SplitByDatasource: "datasource",
+	SplitByServer:     "server",
+	SplitByPhy:        "phy",
+}
+
+func (sm SplitMode) String() string {
+	if name, ok := splitModeNames[sm]; ok {
+		return name
+	}
+	return fmt.Sprintf("SplitMode(%d)", sm)
+}
+
+// ParseSplitMode returns the [SplitMode] named by s, see [SplitMode.String].
+func ParseSplitMode(s string) (SplitMode, error) {
+	for sm, name := range splitModeNames {
+		if strings.EqualFold(s, name) {
+			return sm, nil
+		}
+	}
+	return SplitByTime, fmt.Errorf("unknown split mode: %s", s)
+}
+
+// SplitOptions tunes [SplitKismetDatabase].
+type SplitOptions struct {
+	Mode SplitMode
+	// Window is the width of each partition for [SplitByTime]. It must be a whole number
+	// of hours and defaults to a calendar day.
+	Window time.Duration
+	// Prefix is prepended to every output file name. It defaults to the source's file name.
+	Prefix string
+}
+
+// SplitShard describes one database written by [SplitKismetDatabase].
+type SplitShard struct {
+	// Key is the partition value: a date (or date and hour), datasource uuid, server uuid or phyname.
+	Key  string
+	Path string
+	// Rows holds the number of rows copied into each table.
+	Rows map[string]int64
+}
+
+func (ss *SplitShard) String() string {
+	return fmt.Sprintf("%s: %s (%d devices, %d packets)", ss.Key, ss.Path, ss.Rows["devices"], ss.Rows["packets"])
+}
+
+// splitNullKey labels the shard holding rows whose partition value is NULL.
+const splitNullKey = "none"
+
+// serverUUIDExpr extracts kismet.server.uuid from a device record. The device
+// column is a BLOB, which the JSON functions refuse unless it is cast first.
+const serverUUIDExpr = `json_extract(CAST(device AS TEXT), '$."kismet.server.uuid"')`
+
+// serversSchema holds the server of every device of the source, keyed the ways rows refer to
+// devices, so that splitting by server decodes every device once rather than once per row.
+//
+//goland:noinspection SqlNoDataSourceInspection
+const serversSchema = `
+CREATE TABLE by_devkey (devkey TEXT PRIMARY KEY, server TEXT) WITHOUT ROWID;
+CREATE TABLE by_mac (phyname TEXT, devmac TEXT, server TEXT, PRIMARY KEY(phyname, devmac)) WITHOUT ROWID;
+`
+
+//goland:noinspection SqlResolve
+const fillServers = `
+INSERT OR IGNORE INTO main.by_devkey SELECT devkey, ` + serverUUIDExpr + ` FROM src.devices WHERE devkey IS NOT NULL;
+INSERT OR IGNORE INTO main.by_mac SELECT phyname, devmac, ` + serverUUIDExpr + ` FROM src.devices;
+`
+
+// splitPlan holds, for a single mode, the expression each table is partitioned by.
+// Tables without an entry are copied whole into every shard, while devices and
+// datasources without one are limited to those referenced by the shard's rows.
+type splitPlan struct {
+	keys map[string]string
+	// servers is the scratch database, built from [serversSchema], that the key expressions
+	// of [SplitByServer] look up as "srv".
+	servers string
+}
+
+//goland:noinspection SqlResolve
+func newSplitPlan(opts *SplitOptions) (*splitPlan, error) {
+	switch opts.Mode {
+	case SplitByTime:
+		w := strconv.FormatInt(int64(opts.Window/time.Second), 10)
+		return &splitPlan{keys: map[string]string{
+			"packets":   "ts_sec / " + w,
+			"data":      "ts_sec / " + w,
+			"alerts":    "ts_sec / " + w,
+			"messages":  "ts_sec / " + w,
+			"snapshots": "ts_sec / " + w,
+		}}, nil
+	case SplitByDatasource:
+		return &splitPlan{keys: map[string]string{
+			"packets":     "datasource",
+			"data":        "datasource",
+			"datasources": "uuid",
+		}}, nil
+	case SplitByServer:
+		byDevkey := "(SELECT server FROM srv.by_devkey s WHERE s.devkey = t.devkey)"
+		byMac := "(SELECT server FROM srv.by_mac s WHERE s.phyname = t.phyname AND s.devmac = t.devmac)"
+		return &splitPlan{keys: map[string]string{
+			"devices": byMac,
+			"packets": byDevkey,
+			"data":    byMac,
+			"alerts":  byMac,
+		}}, nil
+	case SplitByPhy:
+		return &splitPlan{keys: map[string]string{
+			"devices": "phyname",
+			"packets": "phyname",
+			"data":    "phyname",
+			"alerts":  "phyname",
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown split mode: %d", opts.Mode)
+	}
+}
+
+// prepare builds the scratch database that the plan's key expressions need, if any, in
+// the source's [KismetDatabase.TmpDir]. The returned func removes it.
+//
+//goland:noinspection SqlResolve
+func (sp *splitPlan) prepare(ctx context.Context, src *KismetDatabase, opts *SplitOptions) (func(), error) {
+	if opts.Mode != SplitByServer {
+		return func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp(src.TmpDir(), "kismet-split-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(dir)
+	}
+	sp.servers = filepath.Join(dir, "servers.db")
+
+	db, err := sql.Open("sqlite", sp.servers)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("sql: %w", wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	db.SetMaxOpenConns(1)
+
+	uri, err := readOnlyURI(src.path)
+	if err == nil {
+		_, err = db.ExecContext(ctx, serversSchema+attachQuery(uri, "src")+fillServers)
+	}
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to map the devices of %s to servers: %w", src.path, wrapSQLiteError(err))
+	}
+
+	return cleanup, nil
+}
+
+// attach attaches the source, read-only, as "src", and the plan's scratch database as "srv"
+// if it has one.
+func (sp *splitPlan) attach(ctx context.Context, conn *sql.Conn, src *KismetDatabase) error {
+	uri, err := readOnlyURI(src.path)
+	if err != nil {
+		return err
+	}
+	if _, err = conn.ExecContext(ctx, attachQuery(uri, "src")); err != nil {
+		return fmt.Errorf("failed to attach %s: %w", src.path, wrapSQLiteError(err))
+	}
+	if sp.servers == "" {
+		return nil
+	}
+	if _, err = conn.ExecContext(ctx, attachQuery(sp.servers, "srv")); err != nil {
+		_, _ = conn.ExecContext(context.Background(), detachQuery("src"))
+		return fmt.Errorf("failed to attach %s: %w", sp.servers, wrapSQLiteError(err))
+	}
+	return nil
+}
+
+// detach undoes [splitPlan.attach]. It runs without a context so that it cleans up after
+// a cancelled split.
+func (sp *splitPlan) detach(conn *sql.Conn, src *KismetDatabase) error {
+	var errs []error
+	if sp.servers != "" {
+		if _, err := conn.ExecContext(context.Background(), detachQuery("srv")); err != nil {
+			errs = append(errs, fmt.Errorf("failed to detach %s: %w", sp.servers, wrapSQLiteError(err)))
+		}
+	}
+	if _, err := conn.ExecContext(context.Background(), detachQuery("src")); err != nil {
+		errs = append(errs, fmt.Errorf("failed to detach %s: %w", src.path, wrapSQLiteError(err)))
+	}
+	return errors.Join(errs...)
+}
+
+// label turns a raw partition value into the shard key used in file names.
+func (sp *splitPlan) label(opts *SplitOptions, raw any) string {
+	switch v := raw.(type) {
+	case nil:
+		return splitNullKey
+	case int64:
+		if opts.Mode != SplitByTime {
+			return strconv.FormatInt(v, 10)
+		}
+		start := time.Unix(v*int64(opts.Window/time.Second), 0).UTC()
+		if opts.Window%(24*time.Hour) == 0 {
+			return start.Format(time.DateOnly)
+		}
+		return start.Format("2006-01-02T15")
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// partitions returns the distinct partition values present in the source.
+//
+//goland:noinspection SqlResolve
+func (sp *splitPlan) partitions(ctx context.Context, src *KismetDatabase) ([]any, error) {
+	var selects []string
+	for table, expr := range sp.keys {
+		if table == "datasources" {
+			// a datasource that captured nothing doesn't warrant a shard of its own
+			continue
+		}
+		selects = append(selects, fmt.Sprintf("SELECT %s AS k FROM src.%s t", expr, table))
+	}
+
+	conn, err := src.reader.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve connection: %w", wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// the key expressions are written against the schema names that shards attach as
+	if err = sp.attach(ctx, conn, src); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sp.detach(conn, src)
+	}()
+
+	rows, err := conn.QueryContext(ctx,
+		"SELECT DISTINCT k FROM ("+strings.Join(selects, " UNION ")+") ORDER BY 1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find partitions of %s: %w", src.path, wrapSQLiteError(err))
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var keys []any
+	for rows.Next() {
+		var k any
+		if err = rows.Scan(&k); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", wrapSQLiteError(err))
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, wrapSQLiteError(rows.Err())
+}
+
+// referencedDevices selects the source devices that rows already copied into the shard refer to.
+//
+//goland:noinspection SqlResolve
+const referencedDevices = `INSERT OR IGNORE INTO main.devices SELECT * FROM src.devices WHERE devkey IN (SELECT devkey FROM main.packets) OR (phyname, devmac) IN (
+	SELECT phyname, devmac FROM main.data UNION SELECT phyname, devmac FROM main.alerts
+	UNION SELECT phyname, sourcemac FROM main.packets UNION SELECT phyname, destmac FROM main.packets UNION SELECT phyname, transmac FROM main.packets
+)`
+
+// referencedDatasources selects the source datasources that rows already copied into the shard refer to.
+//
+//goland:noinspection SqlResolve
+const referencedDatasources = `INSERT OR IGNORE INTO main.datasources SELECT * FROM src.datasources WHERE uuid IN (
+	SELECT datasource FROM main.packets UNION SELECT datasource FROM main.data
+)`
+
+// order is the order tables are filled in, so that devices, datasources and
+// alerts can be limited to those referenced by the rows copied before them.
+func (sp *splitPlan) order() []string {
+	order := []string{"KISMET", "packets", "data"}
+	if _, keyed := sp.keys["alerts"]; keyed {
+		order = append(order, "alerts", "devices")
+	} else {
+		order = append(order, "devices", "alerts")
+	}
+	return append(order, "datasources", "messages", "snapshots")
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SplitKismetDatabase partitions src into one new Kismet database per partition value, written
+// to outDir. Every shard carries the Kismet schema and KISMET row, the rows of its partition,
+// and only the devices and datasources those rows refer to. Messages and snapshots aren't tied
+// to a datasource, server or phy, so every shard receives all of them unless splitting by time.
+func SplitKismetDatabase(src *KismetDatabase, outDir string, opts *SplitOptions) ([]*SplitShard, error) {
+	return SplitKismetDatabaseCtx(context.Background(), src, outDir, opts)
+}
+
+// SplitKismetDatabaseCtx is [SplitKismetDatabase] with a context.
+func SplitKismetDatabaseCtx(ctx context.Context, src *KismetDatabase, outDir string, opts *SplitOptions) ([]*SplitShard, error) {
+	if opts == nil {
+		opts = &SplitOptions{}
+	}
+	normalized := *opts
+	opts = &normalized
+
+	if opts.Window == 0 {
+		opts.Window = 24 * time.Hour
+	}
+	if opts.Mode == SplitByTime && (opts.Window < time.Hour || opts.Window%time.Hour != 0) {
+		return nil, fmt.Errorf("split window must be a whole number of hours, got %s", opts.Window)
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = strings.TrimSuffix(filepath.Base(src.path), filepath.Ext(src.path))
+	}
+
+	plan, err := newSplitPlan(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	cleanup, err := plan.prepare(ctx, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	keys, err := plan.partitions(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := make([]*SplitShard, 0, len(keys))
+
+	for _, key := range keys {
+		label := plan.label(opts, key)
+		shard := &SplitShard{
+			Key:  label,
+			Path: filepath.Join(outDir, opts.Prefix+"-"+unsafeFileChars.ReplaceAllString(label, "_")+".kismet"),
+			Rows: make(map[string]int64),
+		}
+		src.Logger().Info("writing shard", "shard", shard.Key, "path", shard.Path)
+		if err = plan.writeShard(ctx, src, shard, key); err != nil {
+			return shards, err
+		}
+		shards = append(shards, shard)
+	}
+
+	return shards, nil
+}
+
+//goland:noinspection SqlResolve
+func (sp *splitPlan) writeShard(ctx context.Context, src *KismetDatabase, shard *SplitShard, key any) error {
+	if _, err := os.Stat(shard.Path); err == nil {
+		return fmt.Errorf("refusing to overwrite existing shard: %s", shard.Path)
+	}
+
+	out, err := OpenKismetDatabaseCtx(ctx, shard.Path)
+	if err != nil {
+		return err
+	}
+
+	conn, err := out.conn.Conn(ctx)
+	if err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to reserve connection: %w", wrapSQLiteError(err))
+	}
+
+	closeAll := func() {
+		_ = conn.Close()
+		_ = out.Close()
+	}
+
+	if err = sp.attach(ctx, conn, src); err != nil {
+		closeAll()
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		closeAll()
+		return fmt.Errorf("failed to begin transaction: %w", wrapSQLiteError(err))
+	}
+
+	for _, table := range sp.order() {
+		var (
+			query string
+			args  []any
+		)
+
+		expr, keyed := sp.keys[table]
+
+		switch {
+		case keyed:
+			query = fmt.Sprintf("INSERT INTO main.%s SELECT * FROM src.%s t WHERE %s IS ?", table, table, expr)
+			args = append(args, key)
+		case table == "devices":
+			query = referencedDevices
+		case table == "datasources":
+			query = referencedDatasources
+		case table == "alerts":
+			query = "INSERT INTO main.alerts SELECT * FROM src.alerts WHERE (phyname, devmac) IN (SELECT phyname, devmac FROM main.devices)"
+		default:
+			query = fmt.Sprintf("INSERT INTO main.%s SELECT * FROM src.%s", table, table)
+		}
+
+		res, execErr := tx.ExecContext(ctx, query, args...)
+		if execErr != nil {
+			_ = tx.Rollback()
+			closeAll()
+			return fmt.Errorf("failed to copy %s into %s: %w", table, shard.Path, wrapSQLiteError(execErr))
+		}
+		shard.Rows[table], _ = res.RowsAffected()
+	}
+
+	if err = tx.Commit(); err != nil {
+		closeAll()
+		return fmt.Errorf("failed to commit %s: %w", shard.Path, wrapSQLiteError(err))
+	}
+
+	err = sp.detach(conn, src)
+	closeAll()
+
+	return err
+}
@@ -0,0 +1,133 @@
+package data
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func seedSplitDatabase(t *testing.T) *KismetDatabase {
+	t.Helper()
+	db := newTestDatabase(t, "survey.kismet")
+
+	//goland:noinspection SqlResolve
+	stmts := []string{
+		"INSERT INTO KISMET VALUES ('2023-07-R1', 9, 'kismetlog')",
+		`INSERT INTO devices (devkey, phyname, devmac, device) VALUES ('k1', 'IEEE802.11', 'AA:AA:AA:AA:AA:01', '{"kismet.server.uuid": "s1"}')`,
+		`INSERT INTO devices (devkey, phyname, devmac, device) VALUES ('k2', 'IEEE802.11', 'AA:AA:AA:AA:AA:02', '{"kismet.server.uuid": "s2"}')`,
+		`INSERT INTO devices (devkey, phyname, devmac, device) VALUES ('k3', 'Bluetooth', 'BB:BB:BB:BB:BB:03', '{"kismet.server.uuid": "s1"}')`,
+		"INSERT INTO datasources (uuid, name) VALUES ('ds1', 'wlan0'), ('ds2', 'hci0')",
+		// day one, ds1, k1
+		"INSERT INTO packets (ts_sec, phyname, sourcemac, destmac, devkey, datasource) VALUES (86400, 'IEEE802.11', 'AA:AA:AA:AA:AA:01', 'FF:FF:FF:FF:FF:FF', 'k1', 'ds1')",
+		// day two, ds1, k2
+		"INSERT INTO packets (ts_sec, phyname, sourcemac, destmac, devkey, datasource) VALUES (172800, 'IEEE802.11', 'AA:AA:AA:AA:AA:02', 'FF:FF:FF:FF:FF:FF', 'k2', 'ds1')",
+		// day two, ds2, k3
+		"INSERT INTO packets (ts_sec, phyname, sourcemac, destmac, devkey, datasource) VALUES (172900, 'Bluetooth', 'BB:BB:BB:BB:BB:03', '00:00:00:00:00:00', 'k3', 'ds2')",
+		"INSERT INTO alerts (ts_sec, phyname, devmac, header) VALUES (172800, 'IEEE802.11', 'AA:AA:AA:AA:AA:02', 'DEAUTHFLOOD')",
+		"INSERT INTO messages (ts_sec, msgtype, message) VALUES (86400, 'INFO', 'hello')",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.conn.Exec(stmt); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	return db
+}
+
+func TestSplitKismetDatabase(t *testing.T) {
+	// splitting only reads the source
+	src, err := OpenKismetDatabaseReadOnly(seedSplitDatabase(t).String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = src.Close()
+	})
+
+	type want struct{ devices, packets, alerts, messages, datasources int64 }
+
+	cases := []struct {
+		opts *SplitOptions
+		want map[string]want
+	}{
+		{
+			opts: &SplitOptions{Mode: SplitByTime},
+			want: map[string]want{
+				"1970-01-02": {devices: 1, packets: 1, messages: 1, datasources: 1},
+				"1970-01-03": {devices: 2, packets: 2, alerts: 1, datasources: 2},
+			},
+		},
+		{
+			opts: &SplitOptions{Mode: SplitByTime, Window: 6 * time.Hour},
+			want: map[string]want{
+				"1970-01-02T00": {devices: 1, packets: 1, messages: 1, datasources: 1},
+				"1970-01-03T00": {devices: 2, packets: 2, alerts: 1, datasources: 2},
+			},
+		},
+		{
+			opts: &SplitOptions{Mode: SplitByDatasource},
+			want: map[string]want{
+				"ds1": {devices: 2, packets: 2, alerts: 1, messages: 1, datasources: 1},
+				"ds2": {devices: 1, packets: 1, messages: 1, datasources: 1},
+			},
+		},
+		{
+			opts: &SplitOptions{Mode: SplitByServer},
+			want: map[string]want{
+				"s1": {devices: 2, packets: 2, messages: 1, datasources: 2},
+				"s2": {devices: 1, packets: 1, alerts: 1, messages: 1, datasources: 1},
+			},
+		},
+		{
+			opts: &SplitOptions{Mode: SplitByPhy},
+			want: map[string]want{
+				"IEEE802.11": {devices: 2, packets: 2, alerts: 1, messages: 1, datasources: 1},
+				"Bluetooth":  {devices: 1, packets: 1, messages: 1, datasources: 1},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.opts.Mode.String(), func(t *testing.T) {
+			shards, err := SplitKismetDatabase(src, t.TempDir(), c.opts)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if len(shards) != len(c.want) {
+				t.Fatalf("expected %d shards, got %d: %v", len(c.want), len(shards), shards)
+			}
+			for _, shard := range shards {
+				w, ok := c.want[shard.Key]
+				if !ok {
+					t.Errorf("unexpected shard %s", shard.Key)
+					continue
+				}
+				if filepath.Base(shard.Path) != "survey-"+shard.Key+".kismet" {
+					t.Errorf("unexpected shard path %s", shard.Path)
+				}
+				got := want{
+					devices: shard.Rows["devices"], packets: shard.Rows["packets"], alerts: shard.Rows["alerts"],
+					messages: shard.Rows["messages"], datasources: shard.Rows["datasources"],
+				}
+				if got != w {
+					t.Errorf("%s: expected %+v, got %+v", shard.Key, w, got)
+				}
+				if shard.Rows["KISMET"] != 1 {
+					t.Errorf("%s: expected KISMET row to be copied", shard.Key)
+				}
+				out, openErr := OpenKismetDatabase(shard.Path)
+				if openErr != nil {
+					t.Fatal(openErr.Error())
+				}
+				if schemaErr := CheckKismetSchema(out.conn); schemaErr != nil {
+					t.Error(schemaErr.Error())
+				}
+				_ = out.Close()
+			}
+		})
+	}
+
+	if _, err = SplitKismetDatabase(src, t.TempDir(), &SplitOptions{Window: 90 * time.Minute}); err == nil {
+		t.Error("expected error for fractional hour window")
+	}
+}
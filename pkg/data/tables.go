@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TableSelection picks the tables copied by a merge. An empty Include selects every
+// table in the target; Exclude is applied afterwards.
+type TableSelection struct {
+	Include []string
+	Exclude []string
+}
+
+var (
+	// SelectAllTables copies every table.
+	SelectAllTables = TableSelection{}
+	// SelectMetadataOnly copies everything but the raw packets, which make up the bulk of most logs.
+	SelectMetadataOnly = TableSelection{Exclude: []string{"packets"}}
+)
+
+var tablePresets = map[string]TableSelection{
+	"all":      SelectAllTables,
+	"metadata": SelectMetadataOnly,
+}
+
+// ParseTableSelection returns the [TableSelection] for a preset name ("all" or "metadata"),
+// or one that includes the comma separated list of tables in s.
+func ParseTableSelection(s string) (TableSelection, error) {
+	if preset, ok := tablePresets[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return preset, nil
+	}
+	var ts TableSelection
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			ts.Include = append(ts.Include, t)
+		}
+	}
+	if len(ts.Include) == 0 {
+		return ts, fmt.Errorf("empty table selection: %q", s)
+	}
+	return ts, nil
+}
+
+func findTable(tables []string, name string) (string, bool) {
+	idx := slices.IndexFunc(tables, func(t string) bool {
+		return strings.EqualFold(t, name)
+	})
+	if idx < 0 {
+		return "", false
+	}
+	return tables[idx], true
+}
+
+// resolve applies the selection to the available tables, keeping their order and
+// spelling. Naming a table that isn't available is an error, to catch typos.
+func (ts TableSelection) resolve(available []string) ([]string, error) {
+	var errs []error
+
+	selected := available
+	if len(ts.Include) > 0 {
+		selected = make([]string, 0, len(ts.Include))
+		for _, name := range ts.Include {
+			t, ok := findTable(available, name)
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown table: %s", name))
+				continue
+			}
+			if !slices.Contains(selected, t) {
+				selected = append(selected, t)
+			}
+		}
+	}
+
+	var excluded []string
+	for _, name := range ts.Exclude {
+		t, ok := findTable(available, name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown table: %s", name))
+			continue
+		}
+		excluded = append(excluded, t)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	selected = slices.DeleteFunc(slices.Clone(selected), func(t string) bool {
+		return slices.Contains(excluded, t)
+	})
+
+	if len(selected) == 0 {
+		return nil, errors.New("table selection is empty")
+	}
+
+	return selected, nil
+}
+
+// missingTables checks that every one of names exists in db.
+//
+//goland:noinspection SqlNoDataSourceInspection
+func missingTables(ctx context.Context, db *sql.DB, names []string) error {
+	var tErrs = make([]error, 0, len(names))
+	for _, t := range names {
+		var name string
+		err := db.QueryRowContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name = ? COLLATE NOCASE", t).Scan(&name)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			tErrs = append(tErrs, fmt.Errorf("missing table %s", t))
+		case err != nil:
+			tErrs = append(tErrs, fmt.Errorf("failed to look up table %s: %w", t, wrapSQLiteError(err)))
+		}
+	}
+	return errors.Join(tErrs...)
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestTableSelection(t *testing.T) {
+	available := []string{"KISMET", "devices", "packets", "data", "alerts"}
+
+	cases := []struct {
+		sel     string
+		exclude []string
+		want    []string
+		wantErr bool
+	}{
+		{sel: "all", want: available},
+		{sel: "metadata", want: []string{"KISMET", "devices", "data", "alerts"}},
+		{sel: "Devices, alerts", want: []string{"devices", "alerts"}},
+		{sel: "devices,alerts", exclude: []string{"alerts"}, want: []string{"devices"}},
+		{sel: "devices,nope", wantErr: true},
+		{sel: "all", exclude: []string{"nope"}, wantErr: true},
+		{sel: "devices", exclude: []string{"devices"}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		ts, err := ParseTableSelection(c.sel)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		ts.Exclude = append(ts.Exclude, c.exclude...)
+		got, err := ts.resolve(available)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s -%v: expected error, got %v", c.sel, c.exclude, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s -%v: %s", c.sel, c.exclude, err.Error())
+			continue
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s -%v: expected %v, got %v", c.sel, c.exclude, c.want, got)
+		}
+	}
+
+	if _, err := ParseTableSelection(" , "); err == nil {
+		t.Error("expected error for empty selection")
+	}
+}
+
+func TestMergeMetadataOnly(t *testing.T) {
+	source := newTestDatabase(t, "source.kismet")
+	seedTestDatabase(t, source, "00:11:22:33:44:55", 10)
+
+	target := newTestDatabase(t, "target.kismet")
+
+	opts := &MergeOptions{Tidy: TidyNever, Tables: SelectMetadataOnly}
+	if err := MergeKismetDatabasesOpts(target, opts, source.String()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if n := countRows(t, target, "devices"); n != 1 {
+		t.Errorf("expected 1 device, got %d", n)
+	}
+	if n := countRows(t, target, "packets"); n != 0 {
+		t.Errorf("expected no packets, got %d", n)
+	}
+}
+
+func TestMergeChecksSourcesReadOnly(t *testing.T) {
+	source := newTestDatabase(t, "source.kismet")
+	seedTestDatabase(t, source, "00:11:22:33:44:55", 10)
+	//goland:noinspection SqlResolve
+	if _, err := source.conn.Exec("DROP TABLE snapshots"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := source.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	target := newTestDatabase(t, "target.kismet")
+
+	opts := &MergeOptions{Tidy: TidyNever, Tables: TableSelection{Include: []string{"devices", "snapshots"}}}
+	if err := MergeKismetDatabasesOpts(target, opts, source.String()); err == nil {
+		t.Fatal("expected an error for a source without a selected table")
+	}
+
+	// checking the source must not have written the missing table back into it
+	db, err := OpenKismetDatabaseReadOnly(source.String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if slices.Contains(tables, "snapshots") {
+		t.Error("checking the source wrote to it")
+	}
+	if err = db.exec(context.Background(), "write", "CREATE TABLE scratch (x INT)"); err == nil {
+		t.Error("expected writes to a read-only database to fail")
+	}
+}
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// TidyStrategy determines when and how a merge target is compacted.
+type TidyStrategy uint8
+
+const (
+	// TidyAtEnd runs a single VACUUM and PRAGMA optimize once every source has been merged.
+	TidyAtEnd TidyStrategy = iota
+	// TidyNever leaves the target as-is.
+	TidyNever
+	// TidyEveryGroup runs VACUUM and ANALYZE after every group of attached sources.
+	TidyEveryGroup
+	// TidyIncremental switches the target to auto_vacuum=INCREMENTAL and
+	// releases free pages after every group without rewriting the file.
+	TidyIncremental
+	// TidyVacuumInto leaves the target alone and writes a compacted copy of it
+	// to a new file once the merge completes.
+	TidyVacuumInto
+)
+
+var tidyStrategyNames = map[TidyStrategy]string{
+	TidyAtEnd:       "end",
+	TidyNever:       "never",
+	TidyEveryGroup:  "group",
+	TidyIncremental: "incremental",
+	TidyVacuumInto:  "into",
+}
+
+func (ts TidyStrategy) String() string {
+	if name, ok := tidyStrategyNames[ts]; ok {
+		return name
+	}
+	return fmt.Sprintf("TidyStrategy(%d)", ts)
+}
+
+// ParseTidyStrategy returns the [TidyStrategy] named by s, see [TidyStrategy.String].
+func ParseTidyStrategy(s string) (TidyStrategy, error) {
+	for ts, name := range tidyStrategyNames {
+		if strings.EqualFold(s, name) {
+			return ts, nil
+		}
+	}
+	return TidyAtEnd, fmt.Errorf("unknown tidy strategy: %s", s)
+}
+
+// TidyReport describes the outcome of [KismetDatabase.Tidy].
+type TidyReport struct {
+	Strategy TidyStrategy
+	// Path is the file that After was measured from. It differs from the
+	// tidied database only when using [TidyVacuumInto].
+	Path   string
+	Before int64
+	After  int64
+}
+
+func (tr *TidyReport) String() string {
+	return fmt.Sprintf("%s (%s): %s -> %s", tr.Path, tr.Strategy,
+		humanize.IBytes(uint64(tr.Before)), humanize.IBytes(uint64(tr.After)))
+}
+
+// Tidy compacts the database according to strategy. into is only used by
+// [TidyVacuumInto], where it names the file that receives the compacted copy.
+func (kdb *KismetDatabase) Tidy(strategy TidyStrategy, into string) (*TidyReport, error) {
+	return kdb.TidyCtx(context.Background(), strategy, into)
+}
+
+// TidyCtx is [KismetDatabase.Tidy] with a context.
+func (kdb *KismetDatabase) TidyCtx(ctx context.Context, strategy TidyStrategy, into string) (*TidyReport, error) {
+	var err error
+
+	report := &TidyReport{Strategy: strategy, Path: kdb.path}
+	if report.Before, err = kdb.Size(); err != nil {
+		return nil, err
+	}
+
+	switch strategy {
+	case TidyNever:
+	case TidyAtEnd:
+		if err = kdb.VacuumCtx(ctx); err == nil {
+			err = kdb.OptimizeCtx(ctx)
+		}
+	case TidyEveryGroup:
+		if err = kdb.VacuumCtx(ctx); err == nil {
+			err = kdb.AnalyzeCtx(ctx)
+		}
+	case TidyIncremental:
+		if err = kdb.EnableIncrementalVacuumCtx(ctx); err != nil {
+			break
+		}
+		if err = kdb.IncrementalVacuumCtx(ctx); err == nil {
+			err = kdb.OptimizeCtx(ctx)
+		}
+	case TidyVacuumInto:
+		if into == "" {
+			return nil, errors.New("vacuum into requires a destination path")
+		}
+		if err = kdb.OptimizeCtx(ctx); err == nil {
+			err = kdb.VacuumIntoCtx(ctx, into)
+		}
+		report.Path = into
+	default:
+		return nil, fmt.Errorf("unknown tidy strategy: %d", strategy)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if report.After, err = fileSize(report.Path); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTidyStrategy(t *testing.T) {
+	for ts, name := range tidyStrategyNames {
+		parsed, err := ParseTidyStrategy(name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if parsed != ts {
+			t.Errorf("expected %s, got %s", ts, parsed)
+		}
+	}
+	if _, err := ParseTidyStrategy("yeet"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestTidy(t *testing.T) {
+	for ts := range tidyStrategyNames {
+		t.Run(ts.String(), func(t *testing.T) {
+			db := newTestDatabase(t, "tidy.kismet")
+			into := ""
+			if ts == TidyVacuumInto {
+				into = filepath.Join(t.TempDir(), "compacted.kismet")
+			}
+			report, err := db.Tidy(ts, into)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if report.Before == 0 || report.After == 0 {
+				t.Errorf("expected non-zero sizes, got %s", report)
+			}
+			if into == "" {
+				return
+			}
+			if _, err = os.Stat(into); err != nil {
+				t.Fatal(err.Error())
+			}
+			if _, err = db.Tidy(ts, into); err == nil {
+				t.Error("expected error when vacuuming into an existing file")
+			}
+		})
+	}
+}
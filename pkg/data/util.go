@@ -1,78 +1,116 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"os"
 	"slices"
 	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
-
-	"git.tcp.direct/kayos/common/entropy"
 )
 
 func attachQuery(file, name string) string {
-	return "ATTACH" + " '" + file + "' AS " + name + ";"
+	return "ATTACH" + " '" + strings.ReplaceAll(file, "'", "''") + "' AS " + name + ";"
 }
 
 func detachQuery(alias string) string {
 	return "DETACH" + " '" + alias + "';"
 }
 
+// DefaultGroupSize is the number of sources attached at once when [MergeOptions.GroupSize] is unset.
+// It matches SQLite's default SQLITE_MAX_ATTACHED.
+const DefaultGroupSize = 10
+
 func gatherSources(sources ...string) ([][]string, error) {
-	groupedSources := make([][]string, 0, (len(sources)/10)+1)
+	return groupSources(DefaultGroupSize, 0, sources...)
+}
+
+// groupSources splits sources into groups of at most size sources, in order. If maxBytes
+// is positive, a group is also closed before its combined file size would exceed it;
+// a single source larger than maxBytes gets a group of its own.
+func groupSources(size int, maxBytes int64, sources ...string) ([][]string, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid group size: %d", size)
+	}
+
+	groupedSources := make([][]string, 0, (len(sources)/size)+1)
 
 	groupIndex := 0
 	innerIndex := 0
 
+	var groupBytes int64
+
 	for _, source := range sources {
-		if innerIndex == 10 {
+		var sourceBytes int64
+		if maxBytes > 0 {
+			var err error
+			if sourceBytes, err = fileSize(source); err != nil {
+				return nil, err
+			}
+		}
+		if innerIndex == size || (innerIndex > 0 && maxBytes > 0 && groupBytes+sourceBytes > maxBytes) {
 			groupIndex++
 			innerIndex = 0
+			groupBytes = 0
 		}
 		if len(groupedSources) <= groupIndex {
-			groupedSources = append(groupedSources, make([]string, 0, 10))
+			groupedSources = append(groupedSources, make([]string, 0, size))
 		}
 		groupedSources[groupIndex] = append(groupedSources[groupIndex], source)
+		groupBytes += sourceBytes
 		innerIndex++
 	}
 
 	return groupedSources, nil
 }
 
-func checkSource(source string) error {
-	c, err := OpenKismetDatabase(source)
+// checkSource opens source read-only and checks that it is a Kismet log with every one of
+// tables, and that the selection only names tables it has.
+func checkSource(ctx context.Context, source string, selection TableSelection, tables []string) error {
+	c, err := OpenKismetDatabaseReadOnlyCtx(ctx, source)
 	if err != nil {
 		return fmt.Errorf("failed to open kismet database %s: %w", source, err)
 	}
+	defer func() {
+		_ = c.Close()
+	}()
 
-	if err = CheckKismetSchema(c.conn); err != nil {
-		return fmt.Errorf("%s does not appear to be a valid kismet database: %w", source, err)
+	available, err := c.TablesCtx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err = selection.resolve(available); err != nil {
+		return fmt.Errorf("bad table selection for %s: %w", source, err)
 	}
 
-	if err = c.Close(); err != nil {
-		return fmt.Errorf("failed to close kismet database %s: %w", source, err)
+	if err = missingTables(ctx, c.reader, tables); err != nil {
+		return fmt.Errorf("%s is missing selected tables: %w", source, err)
 	}
 
 	return nil
 }
 
 func (kdb *KismetDatabase) Tables() ([]string, error) {
+	return kdb.TablesCtx(context.Background())
+}
+
+// TablesCtx is [KismetDatabase.Tables] with a context.
+func (kdb *KismetDatabase) TablesCtx(ctx context.Context) ([]string, error) {
 	var tables = make([]string, 0)
-	rowsOfTables, err := kdb.tables()
+	rowsOfTables, err := kdb.tables(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed getting target DB tables: %w", err)
+		return nil, fmt.Errorf("failed getting target DB tables: %w", wrapSQLiteError(err))
 	}
 
 	for rowsOfTables.Next() {
 		if errors.Is(rowsOfTables.Err(), sql.ErrNoRows) {
-			return nil, fmt.Errorf("failed getting tables, no rows: %w", err)
+			return nil, fmt.Errorf("failed getting tables, no rows: %w", wrapSQLiteError(err))
 		}
 		var t string
 		if err = rowsOfTables.Scan(&t); err != nil {
-			return nil, fmt.Errorf("failed scanning sqlite table: %w", err)
+			return nil, fmt.Errorf("failed scanning sqlite table: %w", wrapSQLiteError(err))
 		}
 		if strings.Contains(t, "sqlite_stat") {
 			continue
@@ -83,195 +121,268 @@ func (kdb *KismetDatabase) Tables() ([]string, error) {
 	return tables, nil
 }
 
-type mergeTx struct {
-	alias string
-	tx    *sql.Tx
-}
+// ingestSourceGroup attaches every source of a group to the target's write connection at once,
+// copies their tables one source at a time, then detaches them. A source that fails to attach
+// is reported along with the merge of the rest of its group.
+func ingestSourceGroup(ctx context.Context, target *KismetDatabase, tableNames []string, sources []string, opts *MergeOptions) error {
+	if len(sources) > opts.GroupSize {
+		return errors.New("oversized sources slice")
+	}
 
-func newMergeTx(source, alias string, target *KismetDatabase) (*mergeTx, error) {
-	tx, err := target.conn.Begin()
+	conn, err := target.conn.Conn(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	if _, err = tx.Exec(attachQuery(source, alias)); err != nil {
-		return nil, fmt.Errorf("failed to attach %s: %w", source, err)
+		return fmt.Errorf("failed to reserve connection: %w", wrapSQLiteError(err))
 	}
-	return &mergeTx{alias: alias, tx: tx}, nil
-}
-
-func ingestTenSources(target *KismetDatabase, tableNames []string, sources []string) error {
-	if len(sources) > 10 {
-		return errors.New("oversized sources slice")
+	defer func() {
+		_ = conn.Close()
+	}()
+	if _, err = conn.ExecContext(ctx, target.RetryPolicy().busyTimeoutQuery()); err != nil {
+		return fmt.Errorf("failed to set busy timeout: %w", wrapSQLiteError(err))
 	}
 
 	var (
-		merges = make(chan *mergeTx, len(sources))
-		errs1  = make(chan error, len(sources))
-		errs2  = make(chan error, 100)
-		wg     sync.WaitGroup
+		merges   = make([]*mergeConn, 0, len(sources))
+		errGroup []error
 	)
 
 	for i, source := range sources {
-		wg.Add(1)
-		go func(ii int) {
-			defer wg.Done()
-			if err := checkSource(source); err != nil {
-				errs1 <- err
-			}
+		sourceAlias := "db" + strconv.Itoa(i)
+		target.Logger().Info("attaching source", "source", source, "alias", sourceAlias)
+		mc, attachErr := newMergeConn(ctx, conn, source, sourceAlias, target)
+		if attachErr != nil {
+			errGroup = append(errGroup, attachErr)
+			continue
+		}
+		merges = append(merges, mc)
+	}
 
-			sourceAlias := "db" + strconv.Itoa(ii)
-			println("attaching " + source + " as " + sourceAlias + "...")
-			tx, err := newMergeTx(source, sourceAlias, target)
-			if err != nil {
-				errs1 <- err
+	for _, mc := range merges {
+		for _, tn := range tableNames {
+			if err = ctx.Err(); err != nil {
+				errGroup = append(errGroup, fmt.Errorf("stopped merging from %s: %w", mc.alias, err))
+				break
+			}
+			target.Logger().Info("copying table", "source", mc.source, "alias", mc.alias, "table", tn)
+			if err = mc.copyTable(ctx, tn, opts); err != nil {
+				errGroup = append(errGroup, fmt.Errorf("failed to insert values from %s for table %s: %w", mc.alias, tn, err))
 			}
-			merges <- tx
-		}(i)
+		}
 	}
 
-	wg2 := sync.WaitGroup{}
-
-	go func() {
-		wg.Wait()
-		close(merges)
-	}()
-
-	var waitExp = &atomic.Int64{}
-	waitExp.Add(1)
-
-	for incomingMergeTx := range merges {
-		wg2.Add(1)
-
-		mtx := incomingMergeTx
-		wg3 := &sync.WaitGroup{}
-
-		wg3.Add(len(tableNames))
+	for _, mc := range merges {
+		target.Logger().Debug("detaching source", "source", mc.source, "alias", mc.alias)
+		if err = mc.Close(); err != nil {
+			errGroup = append(errGroup, err)
+		}
+	}
 
-		for _, t := range tableNames {
-			go func(mtxInner *mergeTx, tn string) {
-				defer wg3.Done()
+	return errors.Join(errGroup...)
+}
 
-				if mtxInner == nil {
-					return
-				}
+// copyTable copies table from the attached source in chunks of [MergeOptions.ChunkRows] rowids,
+// committing after each one and resuming after the last chunk committed by a previous run.
+// A chunk that finds the database busy is rolled back and retried under the target's [RetryPolicy].
+func (mc *mergeConn) copyTable(ctx context.Context, table string, opts *MergeOptions) error {
+	if table == "" {
+		return errors.New("blank table during attempted merge from " + mc.alias)
+	}
 
-				println("inserting values from", mtxInner.alias, "for table", tn)
+	lastRowID, maxRowID, err := mc.rowidBounds(ctx, table)
+	if err != nil {
+		return err
+	}
 
-				var err = &SQLiteError{}
-				ins := func() *SQLiteError {
-					return mtxInner.attachedInsert(tn, mtxInner.alias)
-				}
+	chunkRows := opts.ChunkRows
+	if chunkRows <= 0 {
+		chunkRows = DefaultChunkRows
+	}
 
-				tries := 0
+	for lastRowID < maxRowID {
+		upTo := min(lastRowID+chunkRows, maxRowID)
 
-				//goland:noinspection GoDirectComparisonOfErrors
-				for {
-					err = ins()
-					if (err != nil && !err.IsBusy()) || err == nil {
-						break
-					}
-					tries++
-					if tries%2 == 1 {
-						waitExp.Add(1)
-					}
-					println(mtxInner.alias + "." + tn + ": database busy (" + strconv.Itoa(int(waitExp.Load())) + "), waiting...")
-					entropy.RandSleepMS(100 * int(waitExp.Load()))
-				}
-				if err != nil {
-					errs2 <- fmt.Errorf("failed to insert values from %s for table %s: %w", mtxInner.alias, tn, err.e)
-				}
+		var copied int64
 
-			}(mtx, t)
+		err = mc.retry.Do(ctx, mc.alias+"."+table, func() (chunkErr error) {
+			copied, chunkErr = mc.copyChunk(ctx, table, lastRowID, upTo)
+			return chunkErr
+		})
+		if err != nil {
+			return err
 		}
 
-		wg3.Wait()
+		lastRowID = upTo
 
-		println("detaching", mtx.alias+"...")
-		if _, err := mtx.tx.Prepare(detachQuery(mtx.alias)); err != nil {
-			errs2 <- fmt.Errorf("failed to detatch %s: %w", mtx.alias, err)
-		}
+		mc.log.Debug("copied chunk", "table", table, "rows", copied, "rowid", lastRowID, "max_rowid", maxRowID)
 
-		println("committing " + mtx.alias + "...")
-		if err := mtx.tx.Commit(); err != nil {
-			errs2 <- fmt.Errorf("failed to commit transaction: %w", err)
+		if opts.OnChunk != nil {
+			opts.OnChunk(ChunkProgress{
+				Source: mc.source, Alias: mc.alias, Table: table,
+				Copied: copied, LastRowID: lastRowID, MaxRowID: maxRowID,
+			})
 		}
-
-		wg2.Done()
 	}
 
-	wg2.Wait()
-
-	close(errs2)
+	return nil
+}
 
-	errGroup := make([]error, 0)
+// MergeOptions tunes [MergeKismetDatabasesOpts]. The zero value is ready to use.
+type MergeOptions struct {
+	// Tidy selects when and how the target is compacted, see [TidyStrategy].
+	Tidy TidyStrategy
+	// VacuumInto names the file that receives the compacted target when Tidy is [TidyVacuumInto].
+	VacuumInto string
+	// Tables selects which of the target's tables are merged, see [SelectMetadataOnly].
+	Tables TableSelection
+	// GroupSize is the number of sources attached at once to the target's write connection,
+	// which copies a group's sources one after the other. It defaults to [DefaultGroupSize],
+	// or the attach limit reported by [KismetDatabase.AttachLimit] if that is lower.
+	GroupSize int
+	// GroupBytes, if positive, caps the combined file size of the sources attached at once.
+	GroupBytes int64
+	// ChunkRows is the number of source rowids copied per transaction, see [DefaultChunkRows].
+	// Every chunk is committed and the target's WAL checkpointed before the next one starts.
+	ChunkRows int64
+	// OnChunk, if set, is called after every committed chunk.
+	OnChunk func(ChunkProgress)
+	// SkipSpaceCheck disables the free disk space preflight, see [KismetDatabase.CheckMergeSpace].
+	SkipSpaceCheck bool
+}
 
-	for e := range errs2 {
-		errGroup = append(errGroup, e)
+func (opts *MergeOptions) validate(ctx context.Context, target *KismetDatabase) error {
+	if opts.GroupSize < 0 {
+		return fmt.Errorf("invalid group size: %d", opts.GroupSize)
 	}
-
-	close(errs1)
-
-	for e := range errs1 {
-		errGroup = append(errGroup, e)
+	if opts.GroupBytes < 0 {
+		return fmt.Errorf("invalid group byte limit: %d", opts.GroupBytes)
 	}
 
-	slices.Reverse(errGroup)
+	limit, err := target.AttachLimitCtx(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.GroupSize == 0:
+		opts.GroupSize = min(DefaultGroupSize, limit)
+	case opts.GroupSize > limit:
+		return fmt.Errorf("group size %d exceeds sqlite attach limit of %d", opts.GroupSize, limit)
+	}
 
-	return errors.Join(errGroup...)
+	if opts.Tidy != TidyVacuumInto {
+		return nil
+	}
+	if opts.VacuumInto == "" {
+		return errors.New("vacuum into requires a destination path")
+	}
+	if _, err := os.Stat(opts.VacuumInto); err == nil {
+		return fmt.Errorf("vacuum into destination already exists: %s", opts.VacuumInto)
+	}
+	return nil
 }
 
-func (mtx *mergeTx) attachedInsert(table, alias string) *SQLiteError {
-	if table == "" {
-		return NewSQLiteError(errors.New("blank table during attempted merge from " + alias))
-	}
-	if alias == "" {
-		return NewSQLiteError(errors.New("blank alias during attempted merge of " + table))
+func tidyUp(ctx context.Context, target *KismetDatabase, strategy TidyStrategy, into string) error {
+	target.Logger().Info("tidying", "strategy", strategy)
+	report, err := target.TidyCtx(ctx, strategy, into)
+	if err != nil {
+		return fmt.Errorf("failed to tidy during merge: %w", err)
 	}
+	target.Logger().Info("tidied", "strategy", report.Strategy, "path", report.Path, "before", report.Before, "after", report.After)
+	return nil
+}
 
-	_, err := mtx.tx.Exec(fmt.Sprintf("INSERT OR IGNORE INTO '%s' SELECT * FROM %s.%s", table, alias, table))
+func MergeKismetDatabases(target *KismetDatabase, sources ...string) error {
+	return MergeKismetDatabasesOpts(target, nil, sources...)
+}
 
-	return NewSQLiteError(err)
+// MergeKismetDatabasesOpts merges sources into target. A nil opts is the same as the zero [MergeOptions].
+func MergeKismetDatabasesOpts(target *KismetDatabase, opts *MergeOptions, sources ...string) error {
+	return MergeKismetDatabasesCtx(context.Background(), target, opts, sources...)
 }
 
-func tidyUp(target *KismetDatabase) error {
-	var err error
+// MergeKismetDatabasesCtx is [MergeKismetDatabasesOpts] with a context. A cancelled merge
+// stops at the next chunk and can be resumed by merging the same sources again.
+func MergeKismetDatabasesCtx(ctx context.Context, target *KismetDatabase, opts *MergeOptions, sources ...string) error {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+	// validate fills in defaults, don't let that leak back to the caller
+	normalized := *opts
+	opts = &normalized
+	if err := opts.validate(ctx, target); err != nil {
+		return err
+	}
 
-	print("\nvacuuming...")
-	if err = target.Vacuum(); err != nil {
-		return fmt.Errorf("failed to vacuum during merge: %w", err)
+	if !opts.SkipSpaceCheck {
+		err := target.CheckMergeSpace(opts, sources...)
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+			target.Logger().Warn("unable to check free disk space on this platform")
+		case err != nil:
+			return fmt.Errorf("merge preflight failed: %w", err)
+		}
 	}
-	print("done\n")
 
-	print("analyzing...")
-	if err = target.Analyze(); err != nil {
-		return fmt.Errorf("failed to analyze during merge: %w", err)
+	grouped, err := groupSources(opts.GroupSize, opts.GroupBytes, sources...)
+	if err != nil {
+		return err
 	}
-	print("done\n\n")
 
-	return nil
-}
+	if err = target.ensureProgressTable(ctx); err != nil {
+		return err
+	}
 
-func MergeKismetDatabases(target *KismetDatabase, sources ...string) error {
-	grouped, err := gatherSources(sources...)
+	tableNames, err := target.TablesCtx(ctx)
 	if err != nil {
 		return err
 	}
+	tableNames = slices.DeleteFunc(tableNames, func(t string) bool {
+		return t == progressTable
+	})
+	if tableNames, err = opts.Tables.resolve(tableNames); err != nil {
+		return fmt.Errorf("bad table selection: %w", err)
+	}
 
-	tableNames, err := target.Tables()
-	if err != nil {
+	var sourceErrs []error
+	for _, source := range sources {
+		if err = checkSource(ctx, source, opts.Tables, tableNames); err != nil {
+			sourceErrs = append(sourceErrs, err)
+		}
+	}
+	if err = errors.Join(sourceErrs...); err != nil {
 		return err
 	}
 
+	if opts.Tidy == TidyIncremental {
+		if err = target.EnableIncrementalVacuumCtx(ctx); err != nil {
+			return err
+		}
+	}
+
 	for _, group := range grouped {
-		if err = ingestTenSources(target, tableNames, group); err != nil {
+		if err = ingestSourceGroup(ctx, target, tableNames, group, opts); err != nil {
+			return err
+		}
+		switch opts.Tidy {
+		case TidyEveryGroup:
+			err = tidyUp(ctx, target, opts.Tidy, "")
+		case TidyIncremental:
+			err = target.IncrementalVacuumCtx(ctx)
+		}
+		if err != nil {
 			return err
 		}
-		if err = tidyUp(target); err != nil {
+	}
+
+	if err = target.dropProgressTable(ctx); err != nil {
+		return err
+	}
+
+	if opts.Tidy != TidyEveryGroup && opts.Tidy != TidyNever {
+		if err = tidyUp(ctx, target, opts.Tidy, opts.VacuumInto); err != nil {
 			return err
 		}
 	}
 
-	println("committing...")
+	target.Logger().Info("merge complete", "sources", len(sources))
 
 	return nil
 }
@@ -1,7 +1,12 @@
 package data
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -36,3 +41,176 @@ func TestGatherSources(t *testing.T) {
 		}
 	}
 }
+
+func seedTestDatabase(t *testing.T, db *KismetDatabase, mac string, packets int) {
+	t.Helper()
+	//goland:noinspection SqlResolve
+	if _, err := db.conn.Exec(
+		"INSERT INTO devices (first_time, last_time, devkey, phyname, devmac, type, device) VALUES (1, 2, ?, 'IEEE802.11', ?, 'Wi-Fi AP', '{}')",
+		mac+"_key", mac,
+	); err != nil {
+		t.Fatal(err.Error())
+	}
+	for i := 0; i < packets; i++ {
+		//goland:noinspection SqlResolve
+		if _, err := db.conn.Exec(
+			"INSERT INTO packets (ts_sec, ts_usec, phyname, sourcemac, destmac, datasource, packetid) VALUES (?, 0, 'IEEE802.11', ?, 'FF:FF:FF:FF:FF:FF', 'test', ?)",
+			i, mac, i,
+		); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func countRows(t *testing.T, db *KismetDatabase, table string) int {
+	t.Helper()
+	var n int
+	if err := db.conn.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err.Error())
+	}
+	return n
+}
+
+func TestMergeKismetDatabases(t *testing.T) {
+	sources := make([]string, 0, 3)
+	for i, mac := range []string{"00:11:22:33:44:01", "00:11:22:33:44:02", "00:11:22:33:44:03"} {
+		src := newTestDatabase(t, "source"+strconv.Itoa(i)+".kismet")
+		seedTestDatabase(t, src, mac, 5)
+		sources = append(sources, src.String())
+	}
+
+	target := newTestDatabase(t, "target.kismet")
+
+	if err := MergeKismetDatabasesOpts(target, &MergeOptions{Tidy: TidyNever}, sources...); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if n := countRows(t, target, "devices"); n != 3 {
+		t.Errorf("expected 3 devices, got %d", n)
+	}
+	if n := countRows(t, target, "packets"); n != 15 {
+		t.Errorf("expected 15 packets, got %d", n)
+	}
+}
+
+func TestMergeKismetDatabasesChunked(t *testing.T) {
+	source := newTestDatabase(t, "source.kismet")
+	seedTestDatabase(t, source, "00:11:22:33:44:55", 10)
+
+	target := newTestDatabase(t, "target.kismet")
+
+	// pretend a previous run committed the first three packets before being interrupted
+	if err := target.ensureProgressTable(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	//goland:noinspection SqlResolve
+	if _, err := target.conn.Exec(
+		"INSERT INTO "+progressTable+" (source, tbl, last_rowid) VALUES (?, 'packets', 3)", source.String(),
+	); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var chunks int
+	opts := &MergeOptions{
+		Tidy:      TidyNever,
+		ChunkRows: 2,
+		OnChunk: func(cp ChunkProgress) {
+			if cp.Table == "packets" {
+				chunks++
+			}
+		},
+	}
+
+	if err := MergeKismetDatabasesOpts(target, opts, source.String()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if n := countRows(t, target, "packets"); n != 7 {
+		t.Errorf("expected 7 packets after resuming, got %d", n)
+	}
+	if chunks != 4 {
+		t.Errorf("expected 4 packet chunks, got %d", chunks)
+	}
+	if tables, _ := target.Tables(); slices.Contains(tables, progressTable) {
+		t.Error("progress table was not dropped after merge")
+	}
+}
+
+func TestMergeKismetDatabasesCancelled(t *testing.T) {
+	source := newTestDatabase(t, "source.kismet")
+	seedTestDatabase(t, source, "00:11:22:33:44:55", 10)
+
+	target := newTestDatabase(t, "target.kismet")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := &MergeOptions{
+		Tidy:      TidyNever,
+		ChunkRows: 2,
+		OnChunk: func(cp ChunkProgress) {
+			if cp.Table == "packets" && cp.LastRowID >= 4 {
+				cancel()
+			}
+		},
+	}
+
+	if err := MergeKismetDatabasesCtx(ctx, target, opts, source.String()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := countRows(t, target, "packets"); n != 4 {
+		t.Errorf("expected the merge to stop after 4 packets, got %d", n)
+	}
+
+	opts.OnChunk = nil
+	if err := MergeKismetDatabasesCtx(context.Background(), target, opts, source.String()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n := countRows(t, target, "packets"); n != 10 {
+		t.Errorf("expected 10 packets after resuming, got %d", n)
+	}
+}
+
+func TestGroupSources(t *testing.T) {
+	dir := t.TempDir()
+	sizes := []int{5, 5, 5, 20, 1, 1, 1}
+	sources := make([]string, 0, len(sizes))
+	for i, size := range sizes {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err.Error())
+		}
+		sources = append(sources, path)
+	}
+
+	grouped, err := groupSources(2, 10, sources...)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var lens []int
+	for _, g := range grouped {
+		lens = append(lens, len(g))
+	}
+	if !slices.Equal(lens, []int{2, 1, 1, 2, 1}) {
+		t.Errorf("unexpected group sizes: %v", lens)
+	}
+
+	if _, err = groupSources(0, 0, sources...); err == nil {
+		t.Error("expected error for zero group size")
+	}
+}
+
+func TestAttachLimit(t *testing.T) {
+	db := newTestDatabase(t, "limit.kismet")
+	limit, err := db.AttachLimit()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if limit < 1 || limit > maxAttachProbe {
+		t.Fatalf("implausible attach limit: %d", limit)
+	}
+	if err = MergeKismetDatabasesOpts(db, &MergeOptions{GroupSize: limit + 1}); err == nil {
+		t.Error("expected error for group size over the attach limit")
+	}
+}
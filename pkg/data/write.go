@@ -0,0 +1,180 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrDeviceNotFound is returned when deleting a device that isn't in the database.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// deviceSummary holds the columns of the devices table that Kismet derives from the device blob,
+// which queries such as those of [DeviceQuery] rely on instead of parsing every device.
+type deviceSummary struct {
+	FirstTime       int
+	LastTime        int
+	DevKey          string
+	PhyName         string
+	DevMac          string
+	StrongestSignal int
+	MinLat, MinLon  float64
+	MaxLat, MaxLon  float64
+	AvgLat, AvgLon  float64
+	BytesData       int
+	Type            string
+}
+
+// summarize derives the summary columns from d the way Kismet does when logging it.
+func summarize(d *Device) deviceSummary {
+	loc := d.BaseLocation
+	return deviceSummary{
+		FirstTime:       d.BaseFirstTime,
+		LastTime:        d.BaseLastTime,
+		DevKey:          d.BaseKey,
+		PhyName:         d.BasePhyname,
+		DevMac:          d.BaseMacaddr,
+		StrongestSignal: d.BaseSignal.CommonSignalMaxSignal,
+		MinLat:          loc.MinLoc.Lat(),
+		MinLon:          loc.MinLoc.Lon(),
+		MaxLat:          loc.MaxLoc.Lat(),
+		MaxLon:          loc.MaxLoc.Lon(),
+		AvgLat:          loc.AvgLoc.Lat(),
+		AvgLon:          loc.AvgLoc.Lon(),
+		BytesData:       d.BaseDatasize,
+		Type:            d.BaseType,
+	}
+}
+
+// deviceColumns lists the columns written for a device, in the order of deviceSummary.values.
+const deviceColumns = "first_time, last_time, devkey, phyname, devmac, strongest_signal, " +
+	"min_lat, min_lon, max_lat, max_lon, avg_lat, avg_lon, bytes_data, type, device"
+
+func (ds deviceSummary) values(blob []byte) []any {
+	return []any{
+		ds.FirstTime, ds.LastTime, ds.DevKey, ds.PhyName, ds.DevMac, ds.StrongestSignal,
+		ds.MinLat, ds.MinLon, ds.MaxLat, ds.MaxLon, ds.AvgLat, ds.AvgLon, ds.BytesData, ds.Type, blob,
+	}
+}
+
+// Tx is a write transaction, see [KismetDatabase.Update].
+type Tx struct {
+	tx *sql.Tx
+}
+
+// Update runs fn in a transaction on the write connection, committing if fn returns nil
+// and rolling back otherwise. A transaction that finds the database busy is rolled back
+// and run again under the database's [RetryPolicy], so fn may be called more than once.
+func (kdb *KismetDatabase) Update(ctx context.Context, fn func(tx *Tx) error) error {
+	rp := kdb.RetryPolicy()
+	if rp.Logger == nil {
+		rp.Logger = kdb.Logger()
+	}
+	err := rp.Do(ctx, "update", func() error {
+		conn, err := kdb.conn.Conn(ctx)
+		if err != nil {
+			return wrapSQLiteError(err)
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		if _, err = conn.ExecContext(ctx, rp.busyTimeoutQuery()); err != nil {
+			return wrapSQLiteError(err)
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return wrapSQLiteError(err)
+		}
+		if err = fn(&Tx{tx: tx}); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return wrapSQLiteError(tx.Commit())
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to update '%s': %w", kdb.path, err)
+	}
+	return err
+}
+
+func (tx *Tx) writeDevice(ctx context.Context, d *Device, upsert bool) error {
+	ds := summarize(d)
+	if ds.PhyName == "" || ds.DevMac == "" {
+		return errors.New("device has no phy or mac")
+	}
+	blob, err := Encode(d)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %s: %w", ds.PhyName, ds.DevMac, err)
+	}
+
+	// OR ABORT overrides the REPLACE that kismet's schema puts on conflicting devices
+	verb := "INSERT OR ABORT"
+	if upsert {
+		verb = "INSERT"
+	}
+	//goland:noinspection SqlResolve
+	query := verb + " INTO devices (" + deviceColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if upsert {
+		// update in place rather than replace, so the device keeps its rowid and with it its place in the log
+		query += " ON CONFLICT (phyname, devmac) DO UPDATE SET " +
+			"first_time = excluded.first_time, last_time = excluded.last_time, devkey = excluded.devkey, " +
+			"strongest_signal = excluded.strongest_signal, min_lat = excluded.min_lat, min_lon = excluded.min_lon, " +
+			"max_lat = excluded.max_lat, max_lon = excluded.max_lon, avg_lat = excluded.avg_lat, avg_lon = excluded.avg_lon, " +
+			"bytes_data = excluded.bytes_data, type = excluded.type, device = excluded.device"
+	}
+	if _, err = tx.tx.ExecContext(ctx, query, ds.values(blob)...); err != nil {
+		return fmt.Errorf("failed to write %s %s: %w", ds.PhyName, ds.DevMac, wrapSQLiteError(err))
+	}
+	return nil
+}
+
+// InsertDevice adds d, failing with [ErrConstraint] if a device with the same phy and MAC exists.
+func (tx *Tx) InsertDevice(ctx context.Context, d *Device) error {
+	return tx.writeDevice(ctx, d, false)
+}
+
+// UpsertDevice adds d, or replaces the device with the same phy and MAC in place.
+func (tx *Tx) UpsertDevice(ctx context.Context, d *Device) error {
+	return tx.writeDevice(ctx, d, true)
+}
+
+// DeleteDevice removes the device with the given phy and MAC, failing with [ErrDeviceNotFound]
+// if there is none. Packets and other records of the device are left alone.
+func (tx *Tx) DeleteDevice(ctx context.Context, phy, mac string) error {
+	mac, err := normalizeMAC(mac)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection SqlResolve
+	res, err := tx.tx.ExecContext(ctx, "DELETE FROM devices WHERE phyname = ? AND devmac = ?", phy, mac)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", phy, mac, wrapSQLiteError(err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s %s: %w", phy, mac, ErrDeviceNotFound)
+	}
+	return nil
+}
+
+// InsertDevice adds d in a transaction of its own, see [Tx.InsertDevice].
+func (kdb *KismetDatabase) InsertDevice(ctx context.Context, d *Device) error {
+	return kdb.Update(ctx, func(tx *Tx) error {
+		return tx.InsertDevice(ctx, d)
+	})
+}
+
+// UpsertDevice adds or replaces d in a transaction of its own, see [Tx.UpsertDevice].
+func (kdb *KismetDatabase) UpsertDevice(ctx context.Context, d *Device) error {
+	return kdb.Update(ctx, func(tx *Tx) error {
+		return tx.UpsertDevice(ctx, d)
+	})
+}
+
+// DeleteDevice removes a device in a transaction of its own, see [Tx.DeleteDevice].
+func (kdb *KismetDatabase) DeleteDevice(ctx context.Context, phy, mac string) error {
+	return kdb.Update(ctx, func(tx *Tx) error {
+		return tx.DeleteDevice(ctx, phy, mac)
+	})
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDeviceWrites(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, "write.kismet")
+
+	d, err := Parse([]byte(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.BaseFirstTime, d.BaseLastTime = 100, 200
+	d.BaseSignal.CommonSignalMaxSignal = -40
+	d.BaseLocation.MinLoc.Geopoint = []float64{13.3, 52.4}
+
+	if err = db.InsertDevice(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.InsertDevice(ctx, d); !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected a duplicate insert to violate a constraint, got %v", err)
+	}
+
+	summary := func() (rowid int64, last, signal int, minLat, avgLat float64, kind string) {
+		t.Helper()
+		//goland:noinspection SqlResolve
+		if err := db.conn.QueryRow(
+			"SELECT rowid, last_time, strongest_signal, min_lat, avg_lat, type FROM devices WHERE devmac = ?", d.BaseMacaddr,
+		).Scan(&rowid, &last, &signal, &minLat, &avgLat, &kind); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	rowid, last, signal, minLat, avgLat, kind := summary()
+	if last != 200 || signal != -40 || minLat != 52.4 || avgLat != 52.5 || kind != "Wi-Fi AP" {
+		t.Errorf("summary columns don't match the device: %d %d %f %f %s", last, signal, minLat, avgLat, kind)
+	}
+
+	d.BaseLastTime, d.BaseType = 300, "Wi-Fi Bridged"
+	if err = db.UpsertDevice(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	upserted, last, _, _, _, kind := summary()
+	if upserted != rowid || last != 300 || kind != "Wi-Fi Bridged" {
+		t.Errorf("expected an in-place update, got rowid %d (was %d), last_time %d, type %s", upserted, rowid, last, kind)
+	}
+
+	iter, err := db.Devices(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices, err := iter.All()
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("expected 1 device, got %d (%v)", len(devices), err)
+	}
+	if _, ok := devices[0].Unknown["kismet.device.base.packet.bin.250"]; !ok {
+		t.Error("unmodelled keys were lost on write")
+	}
+
+	rollback := errors.New("test: rollback")
+	err = db.Update(ctx, func(tx *Tx) error {
+		if err := tx.DeleteDevice(ctx, d.BasePhyname, d.BaseMacaddr); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected the transaction's error, got %v", err)
+	}
+	if n := countRows(t, db, "devices"); n != 1 {
+		t.Fatal("expected the delete to be rolled back")
+	}
+
+	if err = db.DeleteDevice(ctx, d.BasePhyname, d.BaseMacaddr); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.DeleteDevice(ctx, d.BasePhyname, d.BaseMacaddr); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("expected ErrDeviceNotFound, got %v", err)
+	}
+
+	if err = db.InsertDevice(ctx, NewDevice()); err == nil {
+		t.Error("expected a device without a mac to be rejected")
+	}
+}